@@ -0,0 +1,423 @@
+package aggregate
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/enoch300/collectd/collector"
+)
+
+var (
+	ErrInvalidWindow = errors.New("invalid window")
+	ErrStateVersion  = errors.New("unsupported state file version")
+)
+
+const (
+	day                 = 24 * time.Hour
+	defaultSaveInterval = time.Hour
+)
+
+// Window 聚合窗口, 按Size对齐墙上时间切分, 每个窗口结束时计算平均值
+type Window struct {
+	Name string        //窗口名, 用作指标名后缀, 如10m
+	Size time.Duration //窗口长度
+	Keep int           //保留的已结束窗口数, 0表示保留到足够做日同比
+
+	Percentiles []float64 //按已结束窗口的平均值统计的百分位, 如95、99
+}
+
+// keep 保留的已结束窗口数, 至少能做环比和日同比
+func (w Window) keep() int {
+	if w.Keep > 0 {
+		return w.Keep
+	}
+	keep := int(day/w.Size) + 1
+	if keep < 2 {
+		keep = 2
+	}
+	return keep
+}
+
+// DefaultWindows 10分钟、1小时、1天
+var DefaultWindows = []Window{
+	{Name: "10m", Size: 10 * time.Minute},
+	{Name: "1h", Size: time.Hour},
+	{Name: "1d", Size: day},
+}
+
+// BillingWindow 95计费使用的5分钟窗口, 保留30天
+var BillingWindow = Window{
+	Name:        "5m",
+	Size:        5 * time.Minute,
+	Keep:        30 * 24 * 12,
+	Percentiles: []float64{95, 99},
+}
+
+// Bucket 一个窗口的聚合结果
+type Bucket struct {
+	Start int64   //窗口开始时间(unix秒)
+	Sum   float64 //采样值累加和
+	Count int     //采样次数
+}
+
+// Avg 窗口内采样平均值
+func (b Bucket) Avg() float64 {
+	if b.Count == 0 {
+		return 0
+	}
+	return b.Sum / float64(b.Count)
+}
+
+// series 一个指标在一个窗口下的聚合状态
+type series struct {
+	Name    string            `json:"name"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Cur     Bucket            `json:"cur"`     //当前未结束的窗口
+	History []Bucket          `json:"history"` //已结束的窗口, 按时间从旧到新
+}
+
+// observe 加入一个采样, 当前窗口结束时返回true
+func (s *series) observe(start int64, value float64, keep int) bool {
+	if start < s.Cur.Start {
+		//墙上时间回退, 丢弃
+		return false
+	}
+
+	closed := false
+	if start != s.Cur.Start {
+		if s.Cur.Count > 0 {
+			s.History = append(s.History, s.Cur)
+			if len(s.History) > keep {
+				s.History = append(s.History[:0], s.History[len(s.History)-keep:]...)
+			}
+			closed = true
+		}
+		s.Cur = Bucket{Start: start}
+	}
+	s.Cur.Sum += value
+	s.Cur.Count++
+	return closed
+}
+
+// last 最近一个已结束的窗口
+func (s *series) last() (Bucket, bool) {
+	if len(s.History) == 0 {
+		return Bucket{}, false
+	}
+	return s.History[len(s.History)-1], true
+}
+
+// at 开始时间为start的已结束窗口
+func (s *series) at(start int64) (Bucket, bool) {
+	i := sort.Search(len(s.History), func(i int) bool { return s.History[i].Start >= start })
+	if i < len(s.History) && s.History[i].Start == start {
+		return s.History[i], true
+	}
+	return Bucket{}, false
+}
+
+// percentile 已结束窗口平均值的百分位, 按nearest-rank计算:
+// 升序排列后取第ceil(p/100*n)个, 即95计费中去掉最高的5%后的最大值
+func (s *series) percentile(p float64) (float64, bool) {
+	if len(s.History) == 0 || p <= 0 || p > 100 {
+		return 0, false
+	}
+	avgs := make([]float64, len(s.History))
+	for i, b := range s.History {
+		avgs[i] = b.Avg()
+	}
+	sort.Float64s(avgs)
+	rank := int(math.Ceil(p / 100 * float64(len(avgs))))
+	if rank < 1 {
+		rank = 1
+	}
+	return avgs[rank-1], true
+}
+
+// max 已结束窗口平均值的最大值
+func (s *series) max() (float64, bool) {
+	if len(s.History) == 0 {
+		return 0, false
+	}
+	max := math.Inf(-1)
+	for _, b := range s.History {
+		max = math.Max(max, b.Avg())
+	}
+	return max, true
+}
+
+type Option func(a *Aggregator)
+
+// WithName 指定聚合采集器的名称, 默认为被聚合采集器的名称加_window
+func WithName(name string) Option {
+	return func(a *Aggregator) {
+		a.name = name
+	}
+}
+
+// WithWindows 指定聚合窗口, 默认DefaultWindows
+func WithWindows(windows ...Window) Option {
+	return func(a *Aggregator) {
+		a.windows = windows
+	}
+}
+
+// WithMetrics 只聚合指定名称的指标, 默认聚合全部指标
+func WithMetrics(names ...string) Option {
+	return func(a *Aggregator) {
+		a.metrics = make(map[string]bool, len(names))
+		for _, name := range names {
+			a.metrics[name] = true
+		}
+	}
+}
+
+// WithStateFile 指定状态文件, 启动时加载, 有窗口结束且距上次保存超过保存间隔时保存,
+// 使日同比和95计费在重启后仍然可用; 退出前应调用Save, 否则会丢失最近一个保存间隔内的窗口
+func WithStateFile(path string) Option {
+	return func(a *Aggregator) {
+		a.path = path
+	}
+}
+
+// WithSaveInterval 指定状态文件的最短保存间隔, 默认1小时; 每次保存都重写整个文件,
+// 30天的5分钟窗口有8640个, 间隔过短时写入量很大
+func WithSaveInterval(d time.Duration) Option {
+	return func(a *Aggregator) {
+		a.saveInterval = d
+	}
+}
+
+// Aggregator 按窗口聚合指标, 计算环比和日同比
+type Aggregator struct {
+	mu      sync.Mutex
+	windows []Window
+	metrics map[string]bool               //为空时聚合全部指标
+	series  map[string]map[string]*series //窗口名 -> 指标key -> 聚合状态
+	path    string
+	name    string
+
+	saveInterval time.Duration //状态文件的最短保存间隔
+	saved        time.Time     //上次保存状态文件的采样时间
+}
+
+// New 创建聚合器, 指定了状态文件时加载上次保存的状态
+func New(opts ...Option) (*Aggregator, error) {
+	a := &Aggregator{
+		windows:      DefaultWindows,
+		series:       make(map[string]map[string]*series),
+		saveInterval: defaultSaveInterval,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	for _, w := range a.windows {
+		if w.Name == "" || w.Size < time.Second {
+			return nil, ErrInvalidWindow
+		}
+		a.series[w.Name] = make(map[string]*series)
+	}
+	if a.path != "" {
+		if err := a.load(); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// metaLabels 网卡指标中不参与区分序列的标签, 作为元数据随最新的采样更新:
+// IP会随DHCP和地址变更而变化, 容器会随重启而变化, 按它们区分会把同一网卡的计费序列拆开
+var metaLabels = map[string]bool{"ip": true, "container_id": true, "container_name": true}
+
+// Key 指标的唯一标识: name{k1="v1",k2="v2"}, 标签按名称排序;
+// 带iface标签的网卡指标只按网卡(iface、netns)和其余非元数据标签区分, 见metaLabels
+func Key(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	_, isIface := labels["iface"]
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if isIface && metaLabels[k] {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k + `="` + labels[k] + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Observe 加入一次采集的全部采样, 有窗口结束且指定了状态文件时保存状态
+func (a *Aggregator) Observe(t time.Time, samples []collector.Sample) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	closed := false
+	for _, sample := range samples {
+		if len(a.metrics) > 0 && !a.metrics[sample.Name] {
+			continue
+		}
+		key := Key(sample.Name, sample.Labels)
+		for _, w := range a.windows {
+			s, exists := a.series[w.Name][key]
+			if !exists {
+				s = &series{Name: sample.Name}
+				a.series[w.Name][key] = s
+			}
+			s.Labels = sample.Labels
+			if s.observe(windowStart(t, w.Size), sample.Value, w.keep()) {
+				closed = true
+			}
+		}
+	}
+
+	if !closed {
+		return nil
+	}
+	a.expire(t)
+	if a.path == "" || (!a.saved.IsZero() && t.Sub(a.saved) < a.saveInterval) {
+		return nil
+	}
+	if err := a.save(); err != nil {
+		return err
+	}
+	a.saved = t
+	return nil
+}
+
+// expire 删除已经不再出现、历史也已过期的指标
+func (a *Aggregator) expire(t time.Time) {
+	for _, w := range a.windows {
+		oldest := windowStart(t, w.Size) - int64(w.keep())*int64(w.Size/time.Second)
+		for key, s := range a.series[w.Name] {
+			if s.Cur.Start < oldest {
+				delete(a.series[w.Name], key)
+			}
+		}
+	}
+}
+
+// windowStart t所在窗口的开始时间(unix秒), 按UTC对齐
+func windowStart(t time.Time, size time.Duration) int64 {
+	sec := int64(size / time.Second)
+	unix := t.Unix()
+	return unix - ((unix%sec)+sec)%sec
+}
+
+func (a *Aggregator) window(name string) (Window, bool) {
+	for _, w := range a.windows {
+		if w.Name == name {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
+
+// get 查找指标在窗口下的聚合状态, 须持有锁
+func (a *Aggregator) get(key, window string) (*series, Window, bool) {
+	w, ok := a.window(window)
+	if !ok {
+		return nil, Window{}, false
+	}
+	s, exists := a.series[w.Name][key]
+	return s, w, exists
+}
+
+// Last 指标在窗口下最近一个已结束窗口的聚合结果
+func (a *Aggregator) Last(key, window string) (Bucket, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, _, ok := a.get(key, window)
+	if !ok {
+		return Bucket{}, false
+	}
+	return s.last()
+}
+
+// RingOverRing 环比(%): 最近一个已结束窗口与紧邻的上一个窗口的平均值相比
+func (a *Aggregator) RingOverRing(key, window string) (float64, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, w, ok := a.get(key, window)
+	if !ok {
+		return 0, false
+	}
+	return s.compare(int64(w.Size / time.Second))
+}
+
+// DayOverDay 日同比(%): 最近一个已结束窗口与前一天同一时刻的窗口的平均值相比
+func (a *Aggregator) DayOverDay(key, window string) (float64, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, _, ok := a.get(key, window)
+	if !ok {
+		return 0, false
+	}
+	return s.compare(int64(day / time.Second))
+}
+
+// Percentile 指标在窗口下所有已结束窗口平均值的p百分位
+func (a *Aggregator) Percentile(key, window string, p float64) (float64, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, _, ok := a.get(key, window)
+	if !ok {
+		return 0, false
+	}
+	return s.percentile(p)
+}
+
+// Max 指标在窗口下所有已结束窗口平均值的最大值
+func (a *Aggregator) Max(key, window string) (float64, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, _, ok := a.get(key, window)
+	if !ok {
+		return 0, false
+	}
+	return s.max()
+}
+
+// compare 最近一个已结束窗口与offset秒之前的窗口相比的变化率(%)
+func (s *series) compare(offset int64) (float64, bool) {
+	cur, ok := s.last()
+	if !ok {
+		return 0, false
+	}
+	prev, ok := s.at(cur.Start - offset)
+	if !ok {
+		return 0, false
+	}
+	return changeRate(prev.Avg(), cur.Avg()), true
+}
+
+// changeRate 变化率(%), 基准为0时有流量算增长100%
+func changeRate(prev, cur float64) float64 {
+	if prev == 0 {
+		if cur == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (cur - prev) / prev * 100
+}
@@ -0,0 +1,263 @@
+package aggregate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/collector"
+)
+
+var t0 = time.Date(2021, 9, 24, 0, 0, 0, 0, time.UTC)
+
+func recv(zone string, v float64) []collector.Sample {
+	return []collector.Sample{
+		{Name: "net_recv_byte_avg", Labels: map[string]string{"zone": zone}, Value: v},
+		{Name: "net_send_byte_avg", Labels: map[string]string{"zone": zone}, Value: v},
+	}
+}
+
+func mustNew(t *testing.T, opts ...Option) *Aggregator {
+	a, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestKey(t *testing.T) {
+	got := Key("net_iface_speed", map[string]string{"iface": "eth0", "ip": "10.0.0.1", "netns": "", "container_id": "c1"})
+	if want := `net_iface_speed{iface="eth0",netns=""}`; got != want {
+		t.Fatalf("Key = %v, want %v", got, want)
+	}
+	//没有iface标签的指标按全部标签区分
+	got = Key("net_container_recv_byte_avg", map[string]string{"container_id": "c1", "container_name": "web"})
+	if want := `net_container_recv_byte_avg{container_id="c1",container_name="web"}`; got != want {
+		t.Fatalf("Key = %v, want %v", got, want)
+	}
+	if got := Key("tcp_retran_rate", nil); got != "tcp_retran_rate" {
+		t.Fatalf("Key = %v", got)
+	}
+}
+
+func TestIPChangeKeepsSeries(t *testing.T) {
+	a := mustNew(t, WithWindows(Window{Name: "10m", Size: 10 * time.Minute}))
+	sample := func(ip string, v float64) []collector.Sample {
+		return []collector.Sample{{Name: "net_iface_recv_byte_avg", Labels: map[string]string{"iface": "eth0", "ip": ip}, Value: v}}
+	}
+	for i, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.2"} {
+		if err := a.Observe(t0.Add(time.Duration(i)*10*time.Minute), sample(ip, float64(i+1)*100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(a.series["10m"]) != 1 {
+		t.Fatalf("series = %v", a.series["10m"])
+	}
+	key := Key("net_iface_recv_byte_avg", map[string]string{"iface": "eth0", "ip": "10.0.0.2"})
+	if v, ok := a.RingOverRing(key, "10m"); !ok || v != 100 {
+		t.Fatalf("RingOverRing = %v, %v, want 100", v, ok)
+	}
+	//输出使用最新的标签
+	for _, s := range a.Samples() {
+		if s.Labels["ip"] != "10.0.0.2" {
+			t.Fatalf("sample labels = %v", s.Labels)
+		}
+	}
+}
+
+func TestSaveInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "window.json")
+	a := mustNew(t, WithWindows(Window{Name: "1m", Size: time.Minute}), WithStateFile(path), WithSaveInterval(10*time.Minute))
+
+	//每分钟都有窗口结束, 但只在第一次和之后每隔10分钟保存
+	var saves []time.Time
+	for i := 0; i <= 30; i++ {
+		prev := a.saved
+		if err := a.Observe(t0.Add(time.Duration(i)*time.Minute), recv("in", 1)); err != nil {
+			t.Fatal(err)
+		}
+		if a.saved != prev {
+			saves = append(saves, a.saved)
+		}
+	}
+	want := []time.Time{t0.Add(time.Minute), t0.Add(11 * time.Minute), t0.Add(21 * time.Minute)}
+	if len(saves) != len(want) {
+		t.Fatalf("saves = %v", saves)
+	}
+	for i := range want {
+		if !saves[i].Equal(want[i]) {
+			t.Fatalf("saves = %v, want %v", saves, want)
+		}
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRingOverRing(t *testing.T) {
+	a := mustNew(t, WithWindows(Window{Name: "10m", Size: 10 * time.Minute}), WithMetrics("net_recv_byte_avg"))
+	key := Key("net_recv_byte_avg", map[string]string{"zone": "out"})
+
+	//第一个窗口平均100, 第二个窗口平均150
+	for i, v := range []float64{50, 150, 50, 150, 100, 200, 100, 200} {
+		if err := a.Observe(t0.Add(time.Duration(i)*5*time.Minute/2), recv("out", v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := a.RingOverRing(key, "10m"); ok {
+		t.Fatal("ring over ring before two windows are closed")
+	}
+	if err := a.Observe(t0.Add(20*time.Minute), recv("out", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	last, ok := a.Last(key, "10m")
+	if !ok || last.Avg() != 150 || last.Start != t0.Add(10*time.Minute).Unix() {
+		t.Fatalf("Last = %+v, %v", last, ok)
+	}
+	if v, ok := a.RingOverRing(key, "10m"); !ok || v != 50 {
+		t.Fatalf("RingOverRing = %v, %v, want 50", v, ok)
+	}
+	if _, ok := a.Last(Key("net_send_byte_avg", map[string]string{"zone": "out"}), "10m"); ok {
+		t.Fatal("unexpected series for metric not in WithMetrics")
+	}
+
+	//中间缺了窗口时不做环比
+	for _, at := range []time.Duration{50 * time.Minute, 60 * time.Minute} {
+		if err := a.Observe(t0.Add(at), recv("out", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := a.RingOverRing(key, "10m"); ok {
+		t.Fatal("ring over ring across a gap")
+	}
+}
+
+func TestDayOverDayPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "window.json")
+	opts := []Option{WithWindows(Window{Name: "1h", Size: time.Hour}), WithStateFile(path)}
+	key := Key("net_recv_byte_avg", map[string]string{"zone": "in"})
+
+	a := mustNew(t, opts...)
+	for _, obs := range []struct {
+		at time.Duration
+		v  float64
+	}{
+		{0, 200}, {time.Hour, 0},
+	} {
+		if err := a.Observe(t0.Add(obs.at), recv("in", obs.v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//重启后加载昨天的窗口
+	a = mustNew(t, opts...)
+	for _, obs := range []struct {
+		at time.Duration
+		v  float64
+	}{
+		{day, 300}, {day + time.Hour, 0},
+	} {
+		if err := a.Observe(t0.Add(obs.at), recv("in", obs.v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v, ok := a.DayOverDay(key, "1h"); !ok || v != 50 {
+		t.Fatalf("DayOverDay = %v, %v, want 50", v, ok)
+	}
+	if v, ok := a.RingOverRing(key, "1h"); ok {
+		t.Fatalf("RingOverRing = %v across a day gap", v)
+	}
+}
+
+func TestLoadVersion1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "window.json")
+	//版本1的状态文件, 窗口编码为对象
+	v1 := `{"version":1,"series":{"1h":{"net_recv_byte_avg{zone=\"in\"}":{"name":"net_recv_byte_avg","labels":{"zone":"in"},` +
+		`"cur":{"start":1632441600,"sum":0,"count":0},"history":[{"start":1632438000,"sum":400,"count":2}]}}}}`
+	if err := os.WriteFile(path, []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := mustNew(t, WithWindows(Window{Name: "1h", Size: time.Hour}), WithStateFile(path))
+	last, ok := a.Last(Key("net_recv_byte_avg", map[string]string{"zone": "in"}), "1h")
+	if !ok || last.Avg() != 200 || last.Start != t0.Add(-time.Hour).Unix() {
+		t.Fatalf("Last = %+v, %v", last, ok)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version":3,"series":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(WithWindows(Window{Name: "1h", Size: time.Hour}), WithStateFile(path)); err != ErrStateVersion {
+		t.Fatalf("err = %v, want ErrStateVersion", err)
+	}
+}
+
+func TestHistoryKeep(t *testing.T) {
+	a := mustNew(t, WithWindows(Window{Name: "1m", Size: time.Minute, Keep: 3}))
+	for i := 0; i < 10; i++ {
+		if err := a.Observe(t0.Add(time.Duration(i)*time.Minute), recv("in", float64(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := a.series["1m"][Key("net_recv_byte_avg", map[string]string{"zone": "in"})]
+	if len(s.History) != 3 || s.History[0].Avg() != 6 {
+		t.Fatalf("history = %+v", s.History)
+	}
+}
+
+func TestInvalidWindow(t *testing.T) {
+	if _, err := New(WithWindows(Window{Name: "0s"})); err != ErrInvalidWindow {
+		t.Fatalf("err = %v, want ErrInvalidWindow", err)
+	}
+}
+
+type fakeSource struct {
+	samples collector.SampleList
+}
+
+func (f *fakeSource) Name() string                      { return "net" }
+func (f *fakeSource) Collect(ctx context.Context) error { return nil }
+func (f *fakeSource) Snapshot() collector.Snapshot      { return f.samples }
+func (f *fakeSource) Describe() []collector.Desc {
+	return []collector.Desc{{Name: "net_recv_byte_avg", Help: "平均每秒接收字节数", Unit: "byte/s", Labels: []string{"zone"}}}
+}
+
+func TestCollector(t *testing.T) {
+	src := &fakeSource{}
+	c, err := NewCollector(src, WithWindows(Window{Name: "10m", Size: 10 * time.Minute}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name() != "net_window" || len(c.Describe()) != 3 {
+		t.Fatalf("name=%v describe=%+v", c.Name(), c.Describe())
+	}
+
+	now := t0
+	c.now = func() time.Time { return now }
+	for _, v := range []float64{100, 200, 0} {
+		src.samples = recv("out", v)
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(10 * time.Minute)
+	}
+
+	want := map[string]float64{
+		"net_recv_byte_avg_10m_avg":        200,
+		"net_recv_byte_avg_10m_ring_ratio": 100,
+		"net_send_byte_avg_10m_avg":        200,
+		"net_send_byte_avg_10m_ring_ratio": 100,
+	}
+	samples := c.Snapshot().Samples()
+	if len(samples) != len(want) {
+		t.Fatalf("samples = %+v", samples)
+	}
+	for _, s := range samples {
+		if v, exists := want[s.Name]; !exists || v != s.Value || s.Labels["zone"] != "out" {
+			t.Errorf("unexpected sample %+v", s)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package aggregate
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/enoch300/collectd/collector"
+)
+
+// Collector 对另一个采集器的指标做窗口聚合, 需注册在被聚合的采集器之后
+type Collector struct {
+	*Aggregator
+
+	source   collector.Collector
+	snapshot atomic.Value //最近一次的collector.SampleList
+	now      func() time.Time
+}
+
+var _ collector.Collector = (*Collector)(nil)
+
+// NewCollector 创建聚合source指标的采集器
+func NewCollector(source collector.Collector, opts ...Option) (*Collector, error) {
+	a, err := New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := &Collector{Aggregator: a, source: source, now: time.Now}
+	c.snapshot.Store(collector.SampleList{})
+	return c, nil
+}
+
+// Name 默认为被聚合采集器的名称加_window
+func (c *Collector) Name() string {
+	if c.name != "" {
+		return c.name
+	}
+	return c.source.Name() + "_window"
+}
+
+// Collect 聚合被聚合采集器最近一次的快照
+func (c *Collector) Collect(ctx context.Context) error {
+	err := c.Observe(c.now(), c.source.Snapshot().Samples())
+	c.snapshot.Store(c.Samples())
+	return err
+}
+
+// Describe 每个窗口输出平均值、环比和日同比, 配置了百分位的窗口再输出百分位和最大值
+func (c *Collector) Describe() []collector.Desc {
+	var descs []collector.Desc
+	for _, desc := range c.source.Describe() {
+		if len(c.metrics) > 0 && !c.metrics[desc.Name] {
+			continue
+		}
+		for _, w := range c.windows {
+			descs = append(descs,
+				collector.Desc{Name: avgName(desc.Name, w), Help: desc.Help + "(" + w.Name + "平均)", Unit: desc.Unit, Labels: desc.Labels},
+				collector.Desc{Name: ringName(desc.Name, w), Help: desc.Help + "(" + w.Name + "环比)", Unit: "%", Labels: desc.Labels},
+				collector.Desc{Name: dayName(desc.Name, w), Help: desc.Help + "(" + w.Name + "日同比)", Unit: "%", Labels: desc.Labels},
+			)
+			if len(w.Percentiles) == 0 {
+				continue
+			}
+			for _, p := range w.Percentiles {
+				descs = append(descs, collector.Desc{Name: percentileName(desc.Name, w, p), Help: desc.Help + "(" + w.Name + "窗口" + formatPercentile(p) + "百分位)", Unit: desc.Unit, Labels: desc.Labels})
+			}
+			descs = append(descs, collector.Desc{Name: maxName(desc.Name, w), Help: desc.Help + "(" + w.Name + "窗口最大值)", Unit: desc.Unit, Labels: desc.Labels})
+		}
+	}
+	return descs
+}
+
+// Snapshot 最近一次聚合的结果
+func (c *Collector) Snapshot() collector.Snapshot {
+	return c.snapshot.Load().(collector.SampleList)
+}
+
+// Samples 所有指标最近一个已结束窗口的平均值、环比和日同比, 以及配置的百分位和最大值, 没有结果的不输出
+func (a *Aggregator) Samples() collector.SampleList {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var samples collector.SampleList
+	for _, w := range a.windows {
+		sec := int64(w.Size / time.Second)
+		keys := make([]string, 0, len(a.series[w.Name]))
+		for key := range a.series[w.Name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := a.series[w.Name][key]
+			last, ok := s.last()
+			if !ok {
+				continue
+			}
+			samples = append(samples, collector.Sample{Name: avgName(s.Name, w), Labels: s.Labels, Value: last.Avg()})
+			if v, ok := s.compare(sec); ok {
+				samples = append(samples, collector.Sample{Name: ringName(s.Name, w), Labels: s.Labels, Value: v})
+			}
+			if v, ok := s.compare(int64(day / time.Second)); ok {
+				samples = append(samples, collector.Sample{Name: dayName(s.Name, w), Labels: s.Labels, Value: v})
+			}
+			if len(w.Percentiles) == 0 {
+				continue
+			}
+			for _, p := range w.Percentiles {
+				if v, ok := s.percentile(p); ok {
+					samples = append(samples, collector.Sample{Name: percentileName(s.Name, w, p), Labels: s.Labels, Value: v})
+				}
+			}
+			if v, ok := s.max(); ok {
+				samples = append(samples, collector.Sample{Name: maxName(s.Name, w), Labels: s.Labels, Value: v})
+			}
+		}
+	}
+	return samples
+}
+
+func avgName(name string, w Window) string  { return name + "_" + w.Name + "_avg" }
+func ringName(name string, w Window) string { return name + "_" + w.Name + "_ring_ratio" }
+func dayName(name string, w Window) string  { return name + "_" + w.Name + "_day_ratio" }
+func maxName(name string, w Window) string  { return name + "_" + w.Name + "_max" }
+
+func percentileName(name string, w Window, p float64) string {
+	return name + "_" + w.Name + "_p" + strings.Replace(formatPercentile(p), ".", "_", 1)
+}
+
+func formatPercentile(p float64) string {
+	return strconv.FormatFloat(p, 'f', -1, 64)
+}
@@ -0,0 +1,135 @@
+package aggregate
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/collector"
+)
+
+func iface(name string, recv float64) []collector.Sample {
+	return []collector.Sample{
+		{Name: "net_iface_recv_byte_avg", Labels: map[string]string{"iface": name}, Value: recv},
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "billing.json")
+	opts := []Option{WithWindows(BillingWindow), WithStateFile(path)}
+	key := Key("net_iface_recv_byte_avg", map[string]string{"iface": "eth0"})
+
+	//100个5分钟窗口, 平均值分别为1..100, 每个窗口采样两次
+	a := mustNew(t, opts...)
+	for i := 0; i < 100; i++ {
+		start := t0.Add(time.Duration(i) * BillingWindow.Size)
+		v := float64(i + 1)
+		if err := a.Observe(start, iface("eth0", v-0.5)); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Observe(start.Add(time.Minute), iface("eth0", v+0.5)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Observe(t0.Add(100*BillingWindow.Size), iface("eth0", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	//重启后从状态文件恢复
+	a = mustNew(t, opts...)
+	checks := []struct {
+		p    float64
+		want float64
+	}{
+		{95, 95}, {99, 99}, {50, 50}, {100, 100},
+	}
+	for _, c := range checks {
+		if got, ok := a.Percentile(key, "5m", c.p); !ok || got != c.want {
+			t.Errorf("p%v = %v, %v, want %v", c.p, got, ok, c.want)
+		}
+	}
+	if got, ok := a.Max(key, "5m"); !ok || got != 100 {
+		t.Errorf("max = %v, %v", got, ok)
+	}
+	if _, ok := a.Percentile(key, "5m", 0); ok {
+		t.Error("p0 should be invalid")
+	}
+}
+
+func TestPercentileKeep(t *testing.T) {
+	w := Window{Name: "5m", Size: 5 * time.Minute, Keep: 10, Percentiles: []float64{95}}
+	a := mustNew(t, WithWindows(w))
+	key := Key("net_iface_recv_byte_avg", map[string]string{"iface": "eth0"})
+
+	//超过保留期的峰值不再参与计算
+	for i := 0; i <= 20; i++ {
+		v := 1.0
+		if i == 0 {
+			v = 1000
+		}
+		if err := a.Observe(t0.Add(time.Duration(i)*w.Size), iface("eth0", v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, ok := a.Max(key, "5m"); !ok || got != 1 {
+		t.Fatalf("max = %v, %v, want 1", got, ok)
+	}
+}
+
+func TestBillingCollector(t *testing.T) {
+	src := &fakeSource{}
+	c, err := NewCollector(src, WithName("net_billing"), WithWindows(BillingWindow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name() != "net_billing" {
+		t.Fatalf("name = %v", c.Name())
+	}
+
+	names := map[string]bool{}
+	for _, d := range c.Describe() {
+		names[d.Name] = true
+	}
+	for _, name := range []string{"net_recv_byte_avg_5m_p95", "net_recv_byte_avg_5m_p99", "net_recv_byte_avg_5m_max"} {
+		if !names[name] {
+			t.Errorf("missing desc %v", name)
+		}
+	}
+
+	now := t0
+	c.now = func() time.Time { return now }
+	for _, v := range []float64{100, 300, 200, 0} {
+		src.samples = recv("out", v)
+		if err := c.Collect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(BillingWindow.Size)
+	}
+
+	got := map[string]float64{}
+	for _, s := range c.Snapshot().Samples() {
+		if s.Labels["zone"] == "out" {
+			got[s.Name] = s.Value
+		}
+	}
+	want := map[string]float64{
+		"net_recv_byte_avg_5m_p95": 300,
+		"net_recv_byte_avg_5m_p99": 300,
+		"net_recv_byte_avg_5m_max": 300,
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+}
+
+func TestPercentileName(t *testing.T) {
+	if got := percentileName("net_recv_byte_avg", BillingWindow, 99.9); got != "net_recv_byte_avg_5m_p99_9" {
+		t.Fatalf("percentileName = %v", got)
+	}
+}
@@ -0,0 +1,118 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// stateVersion 版本2把窗口编码为数组以缩小状态文件, 版本1的窗口编码为对象, 内容相同, 加载时兼容
+const stateVersion = 2
+
+// state 状态文件内容
+type state struct {
+	Version int                           `json:"version"`
+	Series  map[string]map[string]*series `json:"series"` //窗口名 -> 指标key -> 聚合状态
+}
+
+// Save 保存聚合状态到状态文件
+func (a *Aggregator) Save() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.path == "" {
+		return nil
+	}
+	return a.save()
+}
+
+// save 先写临时文件再改名, 避免进程退出时留下不完整的状态文件, 须持有锁
+func (a *Aggregator) save() error {
+	data, err := json.Marshal(state{Version: stateVersion, Series: a.series})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(a.path), filepath.Base(a.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), a.path)
+}
+
+// load 加载状态文件, 文件不存在时从空状态开始, 已不再配置的窗口被丢弃; 不认识的版本返回ErrStateVersion, 不覆盖原文件
+func (a *Aggregator) load() error {
+	data, err := os.ReadFile(a.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	if st.Version != 1 && st.Version != stateVersion {
+		return ErrStateVersion
+	}
+	for _, w := range a.windows {
+		for _, s := range st.Series[w.Name] {
+			if len(s.History) > w.keep() {
+				s.History = s.History[len(s.History)-w.keep():]
+			}
+			//按当前的Key重新计算, 旧版本按IP等元数据拆开的序列只保留最新的一个
+			key := Key(s.Name, s.Labels)
+			if old, exists := a.series[w.Name][key]; exists && old.Cur.Start >= s.Cur.Start {
+				continue
+			}
+			a.series[w.Name][key] = s
+		}
+	}
+	return nil
+}
+
+// MarshalJSON 编码为[start,sum,count], 一个月的5分钟窗口也能保持较小的状态文件
+func (b Bucket) MarshalJSON() ([]byte, error) {
+	return json.Marshal([3]interface{}{b.Start, b.Sum, b.Count})
+}
+
+func (b *Bucket) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		//版本1编码为{"start":,"sum":,"count":}
+		type v1Bucket Bucket
+		var v v1Bucket
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*b = Bucket(v)
+		return nil
+	}
+	var v [3]json.Number
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	start, err := v[0].Int64()
+	if err != nil {
+		return err
+	}
+	sum, err := v[1].Float64()
+	if err != nil {
+		return err
+	}
+	count, err := v[2].Int64()
+	if err != nil {
+		return err
+	}
+	*b = Bucket{Start: start, Sum: sum, Count: int(count)}
+	return nil
+}
@@ -0,0 +1,43 @@
+package collector
+
+import "context"
+
+// Desc 指标描述
+type Desc struct {
+	Name   string   //指标名
+	Help   string   //指标说明
+	Unit   string   //单位
+	Labels []string //标签名
+}
+
+// Sample 单个指标采样值
+type Sample struct {
+	Name   string            //指标名
+	Labels map[string]string //标签
+	Value  float64           //值
+}
+
+// Snapshot 采集器某一时刻的指标快照
+type Snapshot interface {
+	Samples() []Sample
+}
+
+// SampleList 简单的采样值快照
+type SampleList []Sample
+
+// Samples 返回全部采样值
+func (s SampleList) Samples() []Sample {
+	return s
+}
+
+// Collector 采集器统一接口
+type Collector interface {
+	// Name 采集器名称, 在注册表内唯一
+	Name() string
+	// Collect 执行一次采集
+	Collect(ctx context.Context) error
+	// Describe 返回采集器产出的全部指标描述
+	Describe() []Desc
+	// Snapshot 返回最近一次采集的指标快照
+	Snapshot() Snapshot
+}
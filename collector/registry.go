@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrExists   = errors.New("collector already registered")
+	ErrNotFound = errors.New("collector not found")
+)
+
+type entry struct {
+	c        Collector
+	enabled  bool
+	interval time.Duration //采集间隔, 0表示跟随Run的间隔
+	last     time.Time     //上次采集时间
+}
+
+// Registry 采集器注册表
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]*entry
+	order   []string //注册顺序
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		entries: make(map[string]*entry),
+		order:   []string{},
+	}
+}
+
+// Register 注册采集器, 注册后默认启用
+func (r *Registry) Register(c Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := c.Name()
+	if _, exists := r.entries[name]; exists {
+		return fmt.Errorf("%w: %s", ErrExists, name)
+	}
+	r.entries[name] = &entry{c: c, enabled: true}
+	r.order = append(r.order, name)
+	return nil
+}
+
+// Unregister 移除采集器
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.entries[name]; !exists {
+		return false
+	}
+	delete(r.entries, name)
+	for i, n := range r.order {
+		if n == name {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Enable 启用采集器
+func (r *Registry) Enable(name string) error {
+	return r.update(name, func(e *entry) { e.enabled = true })
+}
+
+// Disable 停用采集器
+func (r *Registry) Disable(name string) error {
+	return r.update(name, func(e *entry) { e.enabled = false })
+}
+
+// SetInterval 设置采集器独立的采集间隔
+func (r *Registry) SetInterval(name string, interval time.Duration) error {
+	return r.update(name, func(e *entry) { e.interval = interval })
+}
+
+func (r *Registry) update(name string, fn func(e *entry)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, exists := r.entries[name]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	fn(e)
+	return nil
+}
+
+// Get 按名称获取采集器
+func (r *Registry) Get(name string) (Collector, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, exists := r.entries[name]
+	if !exists {
+		return nil, false
+	}
+	return e.c, true
+}
+
+// Collectors 按注册顺序返回已启用的采集器
+func (r *Registry) Collectors() []Collector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cs := make([]Collector, 0, len(r.order))
+	for _, name := range r.order {
+		if e := r.entries[name]; e.enabled {
+			cs = append(cs, e.c)
+		}
+	}
+	return cs
+}
+
+// due 返回当前需要采集的采集器, 并记录采集时间
+func (r *Registry) due(now time.Time) []Collector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cs := make([]Collector, 0, len(r.order))
+	for _, name := range r.order {
+		e := r.entries[name]
+		if !e.enabled {
+			continue
+		}
+		if e.interval > 0 && !e.last.IsZero() && now.Sub(e.last) < e.interval {
+			continue
+		}
+		e.last = now
+		cs = append(cs, e.c)
+	}
+	return cs
+}
+
+// CollectAll 依次执行所有已启用采集器, 返回以采集器名称为key的错误
+func (r *Registry) CollectAll(ctx context.Context) map[string]error {
+	return r.collect(ctx, r.Collectors())
+}
+
+func (r *Registry) collect(ctx context.Context, cs []Collector) map[string]error {
+	errs := make(map[string]error)
+	for _, c := range cs {
+		if err := ctx.Err(); err != nil {
+			errs[c.Name()] = err
+			continue
+		}
+		if err := c.Collect(ctx); err != nil {
+			errs[c.Name()] = err
+		}
+	}
+	return errs
+}
+
+// Gather 返回所有已启用采集器的最近一次快照
+func (r *Registry) Gather() map[string][]Sample {
+	ret := make(map[string][]Sample)
+	for _, c := range r.Collectors() {
+		ret[c.Name()] = c.Snapshot().Samples()
+	}
+	return ret
+}
+
+// Describe 返回所有已启用采集器的指标描述
+func (r *Registry) Describe() map[string][]Desc {
+	ret := make(map[string][]Desc)
+	for _, c := range r.Collectors() {
+		ret[c.Name()] = c.Describe()
+	}
+	return ret
+}
+
+// Run 按interval周期调度采集, 直到ctx结束; onErr可为nil
+func (r *Registry) Run(ctx context.Context, interval time.Duration, onErr func(name string, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for name, err := range r.collect(ctx, r.due(time.Now())) {
+			if onErr != nil {
+				onErr(name, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
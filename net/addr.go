@@ -0,0 +1,111 @@
+package net
+
+import (
+	"net"
+)
+
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+
+	ScopeGlobal = "global"
+	ScopeLink   = "link"
+	ScopeHost   = "host"
+)
+
+// Addr 网卡地址
+type Addr struct {
+	IP        string `json:"ip"`         //地址
+	PrefixLen int    `json:"prefix_len"` //前缀长度
+	Family    string `json:"family"`     //ipv4/ipv6
+	Scope     string `json:"scope"`      //global/link/host
+}
+
+// IsIPv4 是否为IPv4地址
+func (a Addr) IsIPv4() bool {
+	return a.Family == FamilyIPv4
+}
+
+// newAddr 转换net.Addr, 不是IP地址时ok为false
+func newAddr(addr net.Addr) (Addr, bool) {
+	var (
+		ip     net.IP
+		prefix = -1
+	)
+	switch v := addr.(type) {
+	case *net.IPNet:
+		ip = v.IP
+		prefix, _ = v.Mask.Size()
+	case *net.IPAddr:
+		ip = v.IP
+	default:
+		ip = parseAddrIP(addr.String())
+	}
+	if ip == nil {
+		return Addr{}, false
+	}
+
+	a := Addr{IP: ip.String(), PrefixLen: prefix, Family: FamilyIPv6, Scope: ScopeGlobal}
+	if ip.To4() != nil {
+		a.Family = FamilyIPv4
+	}
+	if prefix < 0 {
+		a.PrefixLen = 8 * net.IPv6len
+		if a.IsIPv4() {
+			a.PrefixLen = 8 * net.IPv4len
+		}
+	}
+
+	switch {
+	case ip.IsLoopback():
+		a.Scope = ScopeHost
+	case ip.IsLinkLocalUnicast():
+		a.Scope = ScopeLink
+	}
+	return a, true
+}
+
+// ipNet 转换回net.IPNet, 用于生成地址消息
+func (a Addr) ipNet() *net.IPNet {
+	ip, bits := net.ParseIP(a.IP), 8*net.IPv6len
+	if a.IsIPv4() {
+		ip, bits = ip.To4(), 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(a.PrefixLen, bits)}
+}
+
+// newAddrs 转换网卡地址并过滤掉链路本地地址
+func newAddrs(raw []net.Addr) []Addr {
+	addrs := make([]Addr, 0, len(raw))
+	for _, r := range raw {
+		a, ok := newAddr(r)
+		if !ok || a.Scope == ScopeLink {
+			continue
+		}
+		addrs = append(addrs, a)
+	}
+	return addrs
+}
+
+// primaryIP 主IP: 优先第一个IPv4地址, 没有时取第一个IPv6地址
+func primaryIP(addrs []Addr) string {
+	for _, a := range addrs {
+		if a.IsIPv4() {
+			return a.IP
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs[0].IP
+	}
+	return ""
+}
+
+// anyIn 任一地址属于网段列表
+func (l prefixList) anyIn(addrs []Addr) bool {
+	for _, a := range addrs {
+		if l.contains(a.IP) {
+			return true
+		}
+	}
+	return false
+}
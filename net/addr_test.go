@@ -0,0 +1,93 @@
+package net
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func mustCIDR(t *testing.T, s string) net.Addr {
+	ip, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ipNet.IP = ip
+	return ipNet
+}
+
+func TestNewAddrs(t *testing.T) {
+	addrs := newAddrs([]net.Addr{
+		mustCIDR(t, "fe80::1/64"),
+		mustCIDR(t, "2001:db8::5/64"),
+		mustCIDR(t, "169.254.1.1/16"),
+		mustCIDR(t, "10.0.0.5/24"),
+		&net.IPAddr{IP: net.ParseIP("127.0.0.1")},
+	})
+	want := []Addr{
+		{IP: "2001:db8::5", PrefixLen: 64, Family: FamilyIPv6, Scope: ScopeGlobal},
+		{IP: "10.0.0.5", PrefixLen: 24, Family: FamilyIPv4, Scope: ScopeGlobal},
+		{IP: "127.0.0.1", PrefixLen: 32, Family: FamilyIPv4, Scope: ScopeHost},
+	}
+	if len(addrs) != len(want) {
+		t.Fatalf("got %+v", addrs)
+	}
+	for i := range want {
+		if addrs[i] != want[i] {
+			t.Errorf("addr %d = %+v, want %+v", i, addrs[i], want[i])
+		}
+	}
+	if primaryIP(addrs) != "10.0.0.5" {
+		t.Errorf("primary ip = %v", primaryIP(addrs))
+	}
+}
+
+func TestMultiAddrClassification(t *testing.T) {
+	r := StaticResolver{
+		//双栈网卡, 内核先列出IPv6地址, 次IP属于内网
+		"eth0": {Index: 2, Name: "eth0", Addrs: []net.Addr{
+			mustCIDR(t, "2001:db8::10/64"),
+			mustCIDR(t, "203.0.113.10/24"),
+			mustCIDR(t, "10.0.0.10/24"),
+		}},
+		//次IP命中忽略网段
+		"eth1": {Index: 3, Name: "eth1", Addrs: []net.Addr{
+			mustCIDR(t, "198.51.100.1/24"),
+			mustCIDR(t, "192.168.99.1/24"),
+		}},
+		//只有IPv6地址
+		"docker0": {Index: 4, Name: "docker0", Addrs: []net.Addr{
+			mustCIDR(t, "fd00::1/64"),
+		}},
+	}
+	n := NewNetwork([]string{"192.168.99.0/24"}, []string{"lo"}, []string{"10.0.0.0/8"}, []string{},
+		WithRoot("testdata/host/t0"), WithResolver(r))
+	n.now = func() time.Time { return time.Unix(1600000000, 0) }
+
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	snap := n.Current()
+	if len(snap.IfiNames) != 1 {
+		t.Fatalf("got interfaces %v, want only eth0", snap.IfiNames)
+	}
+
+	eth0 := snap.IfiMap["eth0"]
+	if eth0.Ip != "203.0.113.10" || len(eth0.Addrs) != 3 {
+		t.Fatalf("eth0 ip=%v addrs=%+v", eth0.Ip, eth0.Addrs)
+	}
+	if !n.IsInIP(eth0) {
+		t.Fatal("eth0 should be internal by its secondary address")
+	}
+	if ifi, err := n.Lookup("10.0.0.10"); err != nil || ifi.Name != "eth0" {
+		t.Fatalf("lookup by secondary ip: %v %v", ifi, err)
+	}
+
+	n.IPV6 = true
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.Lookup("docker0"); err != nil {
+		t.Fatalf("IPv6 only interface should be collected when IPV6 is set: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package net
+
+import (
+	"errors"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidPrefix = errors.New("invalid network prefix")
+
+// ParsePrefix 解析网段配置, 支持:
+//
+//	10.0.0.0/8, fd00::/8  CIDR
+//	10.0.0.1, fe80::1     单个IP
+//	10., 192.168, 10.1    兼容旧配置的点分前缀, 按整段匹配, 10.1不会匹配10.100.x.x
+//	fd00:                 兼容旧配置的IPv6前缀, 按整组匹配
+func ParsePrefix(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, ErrInvalidPrefix
+	}
+
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, ErrInvalidPrefix
+		}
+		return ipNet, nil
+	}
+
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	if strings.Contains(s, ":") {
+		return parseLegacyPrefix6(s)
+	}
+	return parseLegacyPrefix4(s)
+}
+
+func parseLegacyPrefix4(s string) (*net.IPNet, error) {
+	parts := strings.Split(strings.TrimSuffix(s, "."), ".")
+	if len(parts) > net.IPv4len {
+		return nil, ErrInvalidPrefix
+	}
+
+	ip := make(net.IP, net.IPv4len)
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 8)
+		if err != nil {
+			return nil, ErrInvalidPrefix
+		}
+		ip[i] = byte(v)
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(parts), 8*net.IPv4len)}, nil
+}
+
+func parseLegacyPrefix6(s string) (*net.IPNet, error) {
+	groups := strings.Split(strings.TrimRight(s, ":"), ":")
+	if len(groups) >= net.IPv6len/2 {
+		return nil, ErrInvalidPrefix
+	}
+
+	ip := net.ParseIP(strings.Join(groups, ":") + "::")
+	if ip == nil {
+		return nil, ErrInvalidPrefix
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(16*len(groups), 8*net.IPv6len)}, nil
+}
+
+// prefixList 网段列表
+type prefixList []*net.IPNet
+
+// parsePrefixes 解析网段配置, 忽略无法解析的项
+func parsePrefixes(list []string) prefixList {
+	prefixes := make(prefixList, 0, len(list))
+	for _, s := range list {
+		if ipNet, err := ParsePrefix(s); err == nil {
+			prefixes = append(prefixes, ipNet)
+		}
+	}
+	return prefixes
+}
+
+// contains ip可以是10.0.0.1或10.0.0.1/24的形式
+func (l prefixList) contains(ip string) bool {
+	parsed := parseAddrIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range l {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAddrIP(s string) net.IP {
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		s = s[:i]
+	}
+	return net.ParseIP(s)
+}
+
+// namePatterns 网卡名匹配规则, 含通配符(*?[)时按glob匹配, 否则按前缀匹配
+type namePatterns []string
+
+func (p namePatterns) match(name string) bool {
+	for _, pattern := range p {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if strings.ContainsAny(pattern, "*?[") {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		} else if strings.HasPrefix(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifier 本次采集使用的内外网和忽略规则
+type classifier struct {
+	ignoreIP  prefixList
+	ignoreEth namePatterns
+	inIP      prefixList
+	inEth     namePatterns
+}
+
+func (n *NetWork) classifier() *classifier {
+	return &classifier{
+		ignoreIP:  parsePrefixes(n.IgnoreIP),
+		ignoreEth: namePatterns(n.IgnoreEth),
+		inIP:      parsePrefixes(n.InIP),
+		inEth:     namePatterns(n.InEth),
+	}
+}
+
+// isIn 网卡名匹配InEth或任一地址属于InIP网段即为内网
+func (c *classifier) isIn(ifi *Ifi) bool {
+	return c.inEth.match(ifi.Name) || c.inIP.anyIn(ifi.Addrs)
+}
+
+func (c *classifier) isIgnore(ethName string, addrs []Addr, ipv6 bool) bool {
+	if c.ignoreEth.match(ethName) {
+		return true
+	}
+	if c.ignoreIP.anyIn(addrs) {
+		return true
+	}
+
+	for _, a := range addrs {
+		if a.IsIPv4() {
+			return false
+		}
+	}
+	//只有IPv6地址的网卡
+	return !ipv6
+}
@@ -0,0 +1,101 @@
+package net
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParsePrefix(t *testing.T) {
+	cases := []struct {
+		prefix string
+		want   string
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8"},
+		{"10.1.2.3/16", "10.1.0.0/16"},
+		{"10.", "10.0.0.0/8"},
+		{"192.168", "192.168.0.0/16"},
+		{" 10.1 ", "10.1.0.0/16"},
+		{"10.0.0.5", "10.0.0.5/32"},
+		{"fd00::/8", "fd00::/8"},
+		{"fd00:", "fd00::/16"},
+		{"2001:db8:", "2001:db8::/32"},
+		{"fe80::1", "fe80::1/128"},
+	}
+	for _, c := range cases {
+		ipNet, err := ParsePrefix(c.prefix)
+		if err != nil {
+			t.Errorf("ParsePrefix(%q): %v", c.prefix, err)
+			continue
+		}
+		if ipNet.String() != c.want {
+			t.Errorf("ParsePrefix(%q) = %v, want %v", c.prefix, ipNet, c.want)
+		}
+	}
+
+	for _, bad := range []string{"", "10.300", "eth0", "1.2.3.4.5", "10.0.0.0/33"} {
+		if _, err := ParsePrefix(bad); err != ErrInvalidPrefix {
+			t.Errorf("ParsePrefix(%q) err = %v, want %v", bad, err, ErrInvalidPrefix)
+		}
+	}
+}
+
+func TestPrefixListContains(t *testing.T) {
+	prefixes := parsePrefixes([]string{"10.1", "172.16.0.0/12", "fd00::/8", "bogus"})
+	cases := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"10.1.2.3/24", true},
+		{"10.100.2.3", false},
+		{"172.31.255.1", true},
+		{"172.32.0.1", false},
+		{"fd12::1", true},
+		{"fe80::1", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := prefixes.contains(c.ip); got != c.want {
+			t.Errorf("contains(%q) = %v, want %v", c.ip, got, c.want)
+		}
+	}
+}
+
+func TestNamePatterns(t *testing.T) {
+	patterns := namePatterns{"docker", "eth[12]", "bond*.100"}
+	cases := []struct {
+		name string
+		want bool
+	}{
+		{"docker0", true},
+		{"eth1", true},
+		{"eth2", true},
+		{"eth3", false},
+		{"eth10", false},
+		{"bond0.100", true},
+		{"bond0.200", false},
+	}
+	for _, c := range cases {
+		if got := patterns.match(c.name); got != c.want {
+			t.Errorf("match(%q) = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestClassifyByInEth(t *testing.T) {
+	n := collectFixture(t)
+	if n.Current().InRecvByteAvg != 10000 {
+		t.Fatalf("eth1 should be internal by InIP")
+	}
+
+	//InIP的旧前缀10.不再误匹配, 按网卡名把eth0也归为内网
+	n.InIP = []string{"10.0.0.0/24"}
+	n.InEth = []string{"eth0"}
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	eth0 := n.Current().IfiMap["eth0"]
+	if !n.IsIn(eth0) || n.IsInIP(eth0) {
+		t.Fatalf("eth0 should be internal by name only")
+	}
+}
@@ -0,0 +1,388 @@
+package net
+
+import (
+	"github.com/enoch300/collectd/collector"
+)
+
+var _ collector.Collector = (*NetWork)(nil)
+
+const collectorName = "net"
+
+var zoneLabels = []string{"zone"}
+var ifaceLabels = []string{"iface", "ip", "netns", "container_id", "container_name"}
+var containerLabels = []string{"container_id", "container_name"}
+var bondSlaveLabels = []string{"bond", "slave"}
+var queueLabels = []string{"iface", "dir", "queue"}
+var driverStatLabels = []string{"iface", "stat"}
+var driverCounterLabels = []string{"iface", "counter"}
+
+// zoneMetrics 整机(按内外网区分)指标
+var zoneMetrics = []struct {
+	desc collector.Desc
+	in   func(s *Snapshot) float64
+	out  func(s *Snapshot) float64
+}{
+	{
+		desc: collector.Desc{Name: "net_recv_byte_avg", Help: "平均每秒接收字节数", Unit: "byte/s", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InRecvByteAvg },
+		out:  func(s *Snapshot) float64 { return s.OutRecvByteAvg },
+	},
+	{
+		desc: collector.Desc{Name: "net_send_byte_avg", Help: "平均每秒发送字节数", Unit: "byte/s", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InSendByteAvg },
+		out:  func(s *Snapshot) float64 { return s.OutSendByteAvg },
+	},
+	{
+		desc: collector.Desc{Name: "net_recv_pkg_avg", Help: "平均每秒收包数", Unit: "pkg/s", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InRecvPkgAvg },
+		out:  func(s *Snapshot) float64 { return s.OutRecvPkgAvg },
+	},
+	{
+		desc: collector.Desc{Name: "net_send_pkg_avg", Help: "平均每秒发包数", Unit: "pkg/s", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InSendPkgAvg },
+		out:  func(s *Snapshot) float64 { return s.OutSendPkgAvg },
+	},
+	{
+		desc: collector.Desc{Name: "net_recv_err_pkg_avg", Help: "平均每秒收包错误数", Unit: "pkg/s", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InRecvErrPkgAvg },
+		out:  func(s *Snapshot) float64 { return s.OutRecvErrPkgAvg },
+	},
+	{
+		desc: collector.Desc{Name: "net_send_err_pkg_avg", Help: "平均每秒发包错误数", Unit: "pkg/s", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InSendErrPkgAvg },
+		out:  func(s *Snapshot) float64 { return s.OutSendErrPkgAvg },
+	},
+	{
+		desc: collector.Desc{Name: "net_recv_drop_pkg_avg", Help: "平均每秒收包丢包数", Unit: "pkg/s", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InRecvDropPkgAvg },
+		out:  func(s *Snapshot) float64 { return s.OutRecvDropPkgAvg },
+	},
+	{
+		desc: collector.Desc{Name: "net_send_drop_pkg_avg", Help: "平均每秒发包丢包数", Unit: "pkg/s", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InSendDropPkgAvg },
+		out:  func(s *Snapshot) float64 { return s.OutSendDropPkgAvg },
+	},
+	{
+		desc: collector.Desc{Name: "net_recv_err_rate", Help: "收包错误率", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InRecvErrPkgRate },
+		out:  func(s *Snapshot) float64 { return s.OutRecvErrPkgRate },
+	},
+	{
+		desc: collector.Desc{Name: "net_send_err_rate", Help: "发包错误率", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InSendErrPkgRate },
+		out:  func(s *Snapshot) float64 { return s.OutSendErrPkgRate },
+	},
+	{
+		desc: collector.Desc{Name: "net_recv_drop_rate", Help: "收包丢包率", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InRecvDropPkgRate },
+		out:  func(s *Snapshot) float64 { return s.OutRecvDropPkgRate },
+	},
+	{
+		desc: collector.Desc{Name: "net_send_drop_rate", Help: "发包丢包率", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InSendDropPkgRate },
+		out:  func(s *Snapshot) float64 { return s.OutSendDropPkgRate },
+	},
+	{
+		desc: collector.Desc{Name: "net_recv_max_use_rate", Help: "接收带宽最大使用率", Unit: "%", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InRecvMaxUseRate },
+		out:  func(s *Snapshot) float64 { return s.OutRecvMaxUseRate },
+	},
+	{
+		desc: collector.Desc{Name: "net_recv_avg_use_rate", Help: "接收带宽平均使用率", Unit: "%", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InRecvAvgUseRate },
+		out:  func(s *Snapshot) float64 { return s.OutRecvAvgUseRate },
+	},
+	{
+		desc: collector.Desc{Name: "net_send_max_use_rate", Help: "发送带宽最大使用率", Unit: "%", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InSendMaxUseRate },
+		out:  func(s *Snapshot) float64 { return s.OutSendMaxUseRate },
+	},
+	{
+		desc: collector.Desc{Name: "net_send_avg_use_rate", Help: "发送带宽平均使用率", Unit: "%", Labels: zoneLabels},
+		in:   func(s *Snapshot) float64 { return s.InSendAvgUseRate },
+		out:  func(s *Snapshot) float64 { return s.OutSendAvgUseRate },
+	},
+}
+
+// hostMetrics 整机指标
+var hostMetrics = []struct {
+	desc  collector.Desc
+	value func(s *Snapshot) float64
+}{
+	{
+		desc:  collector.Desc{Name: "net_eth_in_max_use_rate", Help: "所有网卡入带宽最大使用率", Unit: "%"},
+		value: func(s *Snapshot) float64 { return s.EthInMaxUseRate },
+	},
+	{
+		desc:  collector.Desc{Name: "net_eth_out_max_use_rate", Help: "所有网卡出带宽最大使用率", Unit: "%"},
+		value: func(s *Snapshot) float64 { return s.EthOutMaxUseRate },
+	},
+}
+
+// ifaceMetrics 单网卡指标
+var ifaceMetrics = []struct {
+	desc  collector.Desc
+	value func(ifi *Ifi) float64
+	known func(ifi *Ifi) bool //为nil或返回true时才输出
+}{
+	{
+		desc:  collector.Desc{Name: "net_iface_speed", Help: "网卡速率", Unit: "Mb/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.Speed },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_recv_use_rate", Help: "网卡接收带宽使用率", Unit: "%", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.RecvUseRate },
+		known: speedKnown,
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_send_use_rate", Help: "网卡发送带宽使用率", Unit: "%", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.SendUseRate },
+		known: speedKnown,
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_mtu", Help: "网卡MTU", Unit: "byte", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return float64(ifi.MTU) },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_up", Help: "网卡运行状态是否为up", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return boolValue(ifi.OperState == "up") },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_carrier_changes", Help: "网卡链路up/down累计次数", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return float64(ifi.CarrierChanges) },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_carrier_flaps", Help: "网卡一个周期内链路up/down次数", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return float64(ifi.CarrierFlaps) },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_bandwidth_limit", Help: "网卡是否被限速", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return float64(ifi.BandwidthLimit) },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_recv_limit", Help: "网卡接收方向限速", Unit: "Mb/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.RecvLimit },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_send_limit", Help: "网卡发送方向限速", Unit: "Mb/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.SendLimit },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_recv_byte_avg", Help: "网卡平均每秒接收字节数", Unit: "byte/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.RecvByteAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_send_byte_avg", Help: "网卡平均每秒发送字节数", Unit: "byte/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.SendByteAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_recv_pkg_avg", Help: "网卡平均每秒收包数", Unit: "pkg/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.RecvPkgAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_send_pkg_avg", Help: "网卡平均每秒发包数", Unit: "pkg/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.SendPkgAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_recv_err_rate", Help: "网卡收包错误率", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.RecvErrRate },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_recv_drop_rate", Help: "网卡收包丢包率", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.RecvDropRate },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_send_err_rate", Help: "网卡发包错误率", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.SendErrRate },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_send_drop_rate", Help: "网卡发包丢包率", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.SendDropRate },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_recv_fifo_avg", Help: "网卡平均每秒接收FIFO溢出数", Unit: "1/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.RecvFifoAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_recv_frame_avg", Help: "网卡平均每秒接收帧错误数", Unit: "1/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.RecvFrameAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_recv_compressed_avg", Help: "网卡平均每秒接收压缩包数", Unit: "pkg/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.RecvCompressedAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_recv_multicast_avg", Help: "网卡平均每秒接收组播包数", Unit: "pkg/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.RecvMulticastAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_send_fifo_avg", Help: "网卡平均每秒发送FIFO溢出数", Unit: "1/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.SendFifoAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_send_colls_avg", Help: "网卡平均每秒发送冲突数", Unit: "1/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.SendCollsAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_send_carrier_avg", Help: "网卡平均每秒发送载波错误数", Unit: "1/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.SendCarrierAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_send_compressed_avg", Help: "网卡平均每秒发送压缩包数", Unit: "pkg/s", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.SendCompressedAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_recv_queue_imbalance", Help: "网卡接收队列不均衡度, 0均衡, 1全部集中在一个队列", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.RecvQueueImbalance },
+		known: queuesKnown,
+	},
+	{
+		desc:  collector.Desc{Name: "net_iface_send_queue_imbalance", Help: "网卡发送队列不均衡度, 0均衡, 1全部集中在一个队列", Labels: ifaceLabels},
+		value: func(ifi *Ifi) float64 { return ifi.SendQueueImbalance },
+		known: queuesKnown,
+	},
+}
+
+// containerMetrics 单容器指标, 以容器为视角: 容器接收即本机一侧veth发送
+var containerMetrics = []struct {
+	desc  collector.Desc
+	value func(c *ContainerStat) float64
+}{
+	{
+		desc:  collector.Desc{Name: "net_container_recv_byte_avg", Help: "容器平均每秒接收字节数", Unit: "byte/s", Labels: containerLabels},
+		value: func(c *ContainerStat) float64 { return c.RecvByteAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_container_send_byte_avg", Help: "容器平均每秒发送字节数", Unit: "byte/s", Labels: containerLabels},
+		value: func(c *ContainerStat) float64 { return c.SendByteAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_container_recv_pkg_avg", Help: "容器平均每秒收包数", Unit: "pkg/s", Labels: containerLabels},
+		value: func(c *ContainerStat) float64 { return c.RecvPkgAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_container_send_pkg_avg", Help: "容器平均每秒发包数", Unit: "pkg/s", Labels: containerLabels},
+		value: func(c *ContainerStat) float64 { return c.SendPkgAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_container_recv_drop_pkg_avg", Help: "容器平均每秒收包丢包数", Unit: "pkg/s", Labels: containerLabels},
+		value: func(c *ContainerStat) float64 { return c.RecvDropPkgAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_container_send_drop_pkg_avg", Help: "容器平均每秒发包丢包数", Unit: "pkg/s", Labels: containerLabels},
+		value: func(c *ContainerStat) float64 { return c.SendDropPkgAvg },
+	},
+}
+
+// bondSlaveMetrics bond从属网卡指标
+var bondSlaveMetrics = []struct {
+	desc  collector.Desc
+	value func(s *BondSlave) float64
+}{
+	{
+		desc:  collector.Desc{Name: "net_bond_slave_up", Help: "bond从属网卡链路是否为up", Labels: bondSlaveLabels},
+		value: func(s *BondSlave) float64 { return boolValue(s.MiiStatus == miiStatusUp) },
+	},
+	{
+		desc:  collector.Desc{Name: "net_bond_slave_active", Help: "bond从属网卡是否为活动网卡", Labels: bondSlaveLabels},
+		value: func(s *BondSlave) float64 { return boolValue(s.State == bondSlaveActive) },
+	},
+	{
+		desc:  collector.Desc{Name: "net_bond_slave_link_failures", Help: "bond从属网卡链路故障累计次数", Labels: bondSlaveLabels},
+		value: func(s *BondSlave) float64 { return float64(s.LinkFailures) },
+	},
+	{
+		desc:  collector.Desc{Name: "net_bond_slave_link_failure_delta", Help: "bond从属网卡一个周期内链路故障次数", Labels: bondSlaveLabels},
+		value: func(s *BondSlave) float64 { return float64(s.LinkFailureDelta) },
+	},
+}
+
+// queueMetrics 网卡收发队列指标, 只在WithQueues时输出
+var queueMetrics = []struct {
+	desc  collector.Desc
+	value func(q *QueueStat) float64
+}{
+	{
+		desc:  collector.Desc{Name: "net_queue_pkg_avg", Help: "网卡队列平均每秒包数", Unit: "pkg/s", Labels: queueLabels},
+		value: func(q *QueueStat) float64 { return q.PkgAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_queue_byte_avg", Help: "网卡队列平均每秒字节数", Unit: "byte/s", Labels: queueLabels},
+		value: func(q *QueueStat) float64 { return q.ByteAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "net_queue_irq_avg", Help: "网卡队列中断平均每秒次数", Unit: "1/s", Labels: queueLabels},
+		value: func(q *QueueStat) float64 { return q.IRQAvg },
+	},
+}
+
+// driverStatDesc 按include保留的驱动统计, 取值为驱动上报的累计值
+var driverStatDesc = collector.Desc{Name: "net_driver_stat", Help: "网卡驱动统计(ethtool -S)累计值", Labels: driverStatLabels}
+
+// driverCounterMetrics 跨驱动归一化的驱动计数, 只输出驱动提供的计数
+var driverCounterMetrics = []struct {
+	desc  collector.Desc
+	value func(c *DriverCounter) float64
+}{
+	{
+		desc:  collector.Desc{Name: "net_driver_counter", Help: "网卡驱动归一化计数累计值", Labels: driverCounterLabels},
+		value: func(c *DriverCounter) float64 { return float64(c.Value) },
+	},
+	{
+		desc:  collector.Desc{Name: "net_driver_counter_avg", Help: "网卡驱动归一化计数平均每秒增量", Unit: "1/s", Labels: driverCounterLabels},
+		value: func(c *DriverCounter) float64 { return c.Avg },
+	},
+}
+
+// Name 采集器名称
+func (n *NetWork) Name() string {
+	return collectorName
+}
+
+// Describe 网络采集器指标描述
+func (n *NetWork) Describe() []collector.Desc {
+	descs := make([]collector.Desc, 0, len(zoneMetrics)+len(hostMetrics)+len(ifaceMetrics)+len(containerMetrics)+len(bondSlaveMetrics)+len(queueMetrics)+1+len(driverCounterMetrics))
+	for _, m := range zoneMetrics {
+		descs = append(descs, m.desc)
+	}
+	for _, m := range hostMetrics {
+		descs = append(descs, m.desc)
+	}
+	for _, m := range ifaceMetrics {
+		descs = append(descs, m.desc)
+	}
+	for _, m := range containerMetrics {
+		descs = append(descs, m.desc)
+	}
+	for _, m := range bondSlaveMetrics {
+		descs = append(descs, m.desc)
+	}
+	for _, m := range queueMetrics {
+		descs = append(descs, m.desc)
+	}
+	descs = append(descs, driverStatDesc)
+	for _, m := range driverCounterMetrics {
+		descs = append(descs, m.desc)
+	}
+	return descs
+}
+
+// Snapshot 最近一次采集的指标快照
+func (n *NetWork) Snapshot() collector.Snapshot {
+	return n.Current()
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func speedKnown(ifi *Ifi) bool {
+	return ifi.Speed > 0
+}
+
+func queuesKnown(ifi *Ifi) bool {
+	return len(ifi.Queues) > 0
+}
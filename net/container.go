@@ -0,0 +1,188 @@
+package net
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrContainerNotFound = errors.New("container not found")
+
+const (
+	RuntimeDocker     = "docker"
+	RuntimeContainerd = "containerd"
+	RuntimeCrio       = "cri-o"
+	RuntimePodman     = "podman"
+)
+
+// Container 容器
+type Container struct {
+	ID      string //容器ID
+	Name    string //容器名, 查询不到时为空
+	Runtime string //容器运行时 docker/containerd/cri-o/podman
+}
+
+// ContainerResolver 根据容器ID查询容器名等信息
+type ContainerResolver interface {
+	ContainerByID(id string) (*Container, error)
+}
+
+// containerRetainer 缓存查询结果的ContainerResolver, 每个周期用当前仍存在的容器ID调用retain删除其余缓存
+type containerRetainer interface {
+	retain(ids map[string]bool)
+}
+
+// StaticContainers 固定的容器表, 用于测试和回放
+type StaticContainers map[string]*Container
+
+func (s StaticContainers) ContainerByID(id string) (*Container, error) {
+	c, exists := s[id]
+	if !exists {
+		return nil, ErrContainerNotFound
+	}
+	return c, nil
+}
+
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// parseCgroup 从/proc/<pid>/cgroup中找出容器ID和运行时, 例如:
+//
+//	0::/system.slice/docker-<id>.scope
+//	12:memory:/docker/<id>
+//	0::/kubepods.slice/.../cri-containerd-<id>.scope
+//	0::/kubepods/burstable/pod<uid>/crio-<id>
+func parseCgroup(r io.Reader) (id, runtime string, ok bool) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.LastIndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		path := line[i+1:]
+		match := containerIDPattern.FindAllString(path, -1)
+		if len(match) == 0 {
+			continue
+		}
+
+		switch {
+		case strings.Contains(path, "containerd"):
+			runtime = RuntimeContainerd
+		case strings.Contains(path, "crio"):
+			runtime = RuntimeCrio
+		case strings.Contains(path, "libpod"):
+			runtime = RuntimePodman
+		default:
+			runtime = RuntimeDocker
+		}
+		//路径中最后一个ID是容器, 前面的可能是pod沙箱
+		return match[len(match)-1], runtime, true
+	}
+	return "", "", false
+}
+
+// netnsContainer 一个命名空间对应的容器及其veth在本机一侧的ifindex
+type netnsContainer struct {
+	pid       int        //读取时使用的进程, 变化时说明命名空间inode可能已被复用, 重新读取
+	container *Container //nil表示不是容器
+	peers     []int      //容器内veth的iflink, 即本机一侧veth的ifindex
+}
+
+// containerPeers 从list中找出容器的命名空间, 返回本机veth ifindex到容器的映射和命名空间inode到容器的映射;
+// 容器按命名空间inode缓存, 命名空间消失后删除; veth可能在容器存续期间重建, 对端ifindex每个周期重新读取
+func (n *NetWork) containerPeers(list []Netns) (map[int]*Container, map[uint64]*Container) {
+	byPeer := make(map[int]*Container)
+	byInode := make(map[uint64]*Container)
+	if n.containers == nil {
+		return byPeer, byInode
+	}
+
+	seen := make(map[uint64]bool)
+	ids := make(map[string]bool)
+	for _, ns := range list {
+		if ns.Pid == 0 {
+			continue
+		}
+		seen[ns.Inode] = true
+
+		nc, exists := n.containerCache[ns.Inode]
+		if !exists || nc.pid != ns.Pid {
+			nc = n.readNetnsContainer(ns.Pid)
+			n.containerCache[ns.Inode] = nc
+		} else if nc.container != nil {
+			nc.peers = n.readNetnsPeers(ns.Pid)
+		}
+		if nc.container == nil {
+			continue
+		}
+		ids[nc.container.ID] = true
+		byInode[ns.Inode] = nc.container
+		for _, peer := range nc.peers {
+			byPeer[peer] = nc.container
+		}
+	}
+
+	for inode := range n.containerCache {
+		if !seen[inode] {
+			delete(n.containerCache, inode)
+		}
+	}
+	if r, ok := n.containers.(containerRetainer); ok {
+		r.retain(ids)
+	}
+	return byPeer, byInode
+}
+
+// readNetnsContainer 根据命名空间内进程的cgroup找到容器, 再读取veth的对端; 不是容器时container为nil
+func (n *NetWork) readNetnsContainer(pid int) *netnsContainer {
+	nc := &netnsContainer{pid: pid}
+	f, err := n.fs.Open(n.fs.Proc(strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return nc
+	}
+	id, runtime, ok := parseCgroup(f)
+	f.Close()
+	if !ok {
+		return nc
+	}
+
+	c, err := n.containers.ContainerByID(id)
+	if err != nil {
+		c = &Container{ID: id}
+	}
+	if c.Runtime == "" {
+		cp := *c
+		cp.Runtime = runtime
+		c = &cp
+	}
+	nc.container = c
+	nc.peers = n.readNetnsPeers(pid)
+	return nc
+}
+
+// readNetnsPeers 从容器的/sys/class/net读取veth的iflink, 即本机一侧veth的ifindex
+func (n *NetWork) readNetnsPeers(pid int) []int {
+	//容器内挂载的sysfs属于容器的命名空间
+	dir := n.fs.Proc(strconv.Itoa(pid), "root", "sys", "class", "net")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var peers []int
+	for _, e := range entries {
+		index, err := n.fs.ReadInt(dir + "/" + e.Name() + "/ifindex")
+		if err != nil {
+			continue
+		}
+		iflink, err := n.fs.ReadInt(dir + "/" + e.Name() + "/iflink")
+		if err != nil || iflink == index {
+			continue
+		}
+		peers = append(peers, int(iflink))
+	}
+	return peers
+}
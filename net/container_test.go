@@ -0,0 +1,242 @@
+package net
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/procfs"
+)
+
+const testContainerID = "3f2a9c1d5e7b8a6f4c2d0e1b9a8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c"
+
+func TestParseCgroup(t *testing.T) {
+	tests := []struct {
+		cgroup  string
+		id      string
+		runtime string
+	}{
+		{"0::/system.slice/docker-" + testContainerID + ".scope", testContainerID, RuntimeDocker},
+		{"12:memory:/docker/" + testContainerID + "\n11:cpu:/docker/" + testContainerID, testContainerID, RuntimeDocker},
+		{"0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + testContainerID + ".scope", testContainerID, RuntimeContainerd},
+		{"0::/kubepods/besteffort/pod5678/crio-" + testContainerID, testContainerID, RuntimeCrio},
+		{"0::/machine.slice/libpod-" + testContainerID + ".scope/container", testContainerID, RuntimePodman},
+		{"0::/user.slice/user-1000.slice/session-1.scope", "", ""},
+	}
+	for _, tt := range tests {
+		id, runtime, ok := parseCgroup(strings.NewReader(tt.cgroup))
+		if ok != (tt.id != "") || id != tt.id || runtime != tt.runtime {
+			t.Errorf("parseCgroup(%q) = %q, %q, %v", tt.cgroup, id, runtime, ok)
+		}
+	}
+}
+
+func newContainerNetwork(t *testing.T, containers ContainerResolver) (*NetWork, *time.Time) {
+	r, err := LoadIPAddr("testdata/containers/ip-addr.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1600000000, 0)
+	n := NewNetwork([]string{}, []string{"lo"}, []string{"10."}, []string{},
+		WithRoot("testdata/containers/t0"), WithResolver(r), WithContainers(containers))
+	n.now = func() time.Time { return now }
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(10 * time.Second)
+	n.fs = procfs.NewFS("testdata/containers/t1")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return n, &now
+}
+
+func TestCollectContainers(t *testing.T) {
+	n, _ := newContainerNetwork(t, StaticContainers{
+		testContainerID: {ID: testContainerID, Name: "web"},
+	})
+	s := n.Current()
+
+	veth, err := s.IfiByName("veth1a2b3c4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if veth.ContainerID != testContainerID || veth.ContainerName != "web" {
+		t.Fatalf("veth attributed to %q %q", veth.ContainerID, veth.ContainerName)
+	}
+	//对端不是容器的veth没有地址, 仍然忽略
+	if _, err := s.IfiByName("vethfeed"); err != ErrIfiNotFound {
+		t.Fatalf("got %v, want %v", err, ErrIfiNotFound)
+	}
+
+	cs, exists := s.Containers[testContainerID]
+	if !exists {
+		t.Fatalf("container not found: %v", s.ContainerIDs)
+	}
+	if cs.Name != "web" || cs.Runtime != RuntimeDocker || len(cs.Ifaces) != 1 {
+		t.Fatalf("unexpected container: %+v", cs)
+	}
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"RecvByteAvg", cs.RecvByteAvg, 20000},
+		{"SendByteAvg", cs.SendByteAvg, 10000},
+		{"RecvPkgAvg", cs.RecvPkgAvg, 40},
+		{"SendPkgAvg", cs.SendPkgAvg, 20},
+		{"RecvDropPkgAvg", cs.RecvDropPkgAvg, 0.2},
+		//veth不计入整机统计
+		{"OutRecvByteAvg", s.OutRecvByteAvg, 110000},
+		{"OutSendByteAvg", s.OutSendByteAvg, 220000},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	found := false
+	for _, sample := range s.Samples() {
+		if sample.Name == "net_container_recv_byte_avg" {
+			found = true
+			if sample.Labels["container_id"] != testContainerID || sample.Labels["container_name"] != "web" || sample.Value != 20000 {
+				t.Errorf("unexpected sample: %+v", sample)
+			}
+		}
+		if sample.Name == "net_iface_send_byte_avg" && sample.Labels["iface"] == "veth1a2b3c4" && sample.Labels["container_name"] != "web" {
+			t.Errorf("iface sample missing container label: %+v", sample)
+		}
+	}
+	if !found {
+		t.Fatal("net_container_recv_byte_avg not reported")
+	}
+}
+
+func TestCollectContainersUnknownName(t *testing.T) {
+	n, _ := newContainerNetwork(t, StaticContainers{})
+
+	veth, err := n.Current().IfiByName("veth1a2b3c4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if veth.ContainerID != testContainerID || veth.ContainerName != "" {
+		t.Fatalf("veth attributed to %q %q", veth.ContainerID, veth.ContainerName)
+	}
+}
+
+func TestCollectWithoutContainers(t *testing.T) {
+	n, _ := newContainerNetwork(t, nil)
+	if _, err := n.Current().IfiByName("veth1a2b3c4"); err != ErrIfiNotFound {
+		t.Fatalf("got %v, want %v", err, ErrIfiNotFound)
+	}
+	if len(n.Current().Containers) != 0 {
+		t.Fatalf("got containers %v", n.Current().ContainerIDs)
+	}
+}
+
+func TestDockerResolver(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip(err)
+	}
+	requests := 0
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/containers/"+testContainerID+"/json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"Id":"` + testContainerID + `","Name":"/web"}`))
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	r := NewDockerResolver(socket)
+	for i := 0; i < 2; i++ {
+		c, err := r.ContainerByID(testContainerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Name != "web" || c.Runtime != RuntimeDocker {
+			t.Fatalf("got %+v", c)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("got %d requests, want 1", requests)
+	}
+
+	//容器消失后删除缓存, 再次出现时重新查询
+	r.retain(map[string]bool{})
+	if _, err := r.ContainerByID(testContainerID); err != nil || requests != 2 {
+		t.Fatalf("got %d requests, %v", requests, err)
+	}
+	if _, err := r.ContainerByID("missing"); err != ErrContainerNotFound {
+		t.Fatalf("got %v, want %v", err, ErrContainerNotFound)
+	}
+}
+
+type retainingContainers struct {
+	StaticContainers
+	retained map[string]bool
+}
+
+func (r *retainingContainers) retain(ids map[string]bool) {
+	r.retained = ids
+}
+
+func TestContainerPeersRevalidate(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, value string) {
+		path = filepath.Join(root, "proc", path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("300/cgroup", "0::/system.slice/docker-"+testContainerID+".scope")
+	write("300/root/sys/class/net/eth0/ifindex", "2")
+	write("300/root/sys/class/net/eth0/iflink", "7")
+	write("301/cgroup", "0::/user.slice/user-1000.slice/session-1.scope")
+
+	containers := &retainingContainers{StaticContainers: StaticContainers{testContainerID: {ID: testContainerID, Name: "web"}}}
+	n := NewNetwork([]string{}, []string{"lo"}, []string{}, []string{}, WithRoot(root), WithContainers(containers))
+
+	byPeer, _ := n.containerPeers([]Netns{{Inode: 9, Pid: 300}})
+	if c := byPeer[7]; c == nil || c.Name != "web" {
+		t.Fatalf("byPeer = %v", byPeer)
+	}
+	if !containers.retained[testContainerID] {
+		t.Fatalf("retained = %v", containers.retained)
+	}
+
+	//veth重建后对端ifindex变化
+	write("300/root/sys/class/net/eth0/iflink", "12")
+	byPeer, _ = n.containerPeers([]Netns{{Inode: 9, Pid: 300}})
+	if byPeer[7] != nil || byPeer[12] == nil {
+		t.Fatalf("stale peer: %v", byPeer)
+	}
+
+	//命名空间inode被不是容器的进程复用
+	byPeer, byInode := n.containerPeers([]Netns{{Inode: 9, Pid: 301}})
+	if len(byPeer) != 0 || len(byInode) != 0 {
+		t.Fatalf("stale container: %v %v", byPeer, byInode)
+	}
+	if len(containers.retained) != 0 {
+		t.Fatalf("retained = %v", containers.retained)
+	}
+
+	n.containerPeers(nil)
+	if len(n.containerCache) != 0 {
+		t.Fatalf("cache = %v", n.containerCache)
+	}
+}
@@ -0,0 +1,88 @@
+package net
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	maxWrapDelta32 = 1 << 31 //32位计数器回绕后允许的最大增量
+	maxWrapDelta64 = 1 << 63 //64位计数器回绕后允许的最大增量
+)
+
+// counterWidth 计数器的位数, 由数据来源决定, 不能从取值推断:
+// 64位计数器在[2^31, 2^32)之间被重置时看起来与32位回绕相同
+type counterWidth int
+
+const (
+	counter32 counterWidth = 32 //如/proc/interrupts中每个CPU的中断次数
+	counter64 counterWidth = 64 //如IFLA_STATS64和ethtool统计
+
+	//counterProcDev /proc/net/dev的计数是unsigned long, 与内核字长相同, 32位内核上会回绕;
+	//按agent的字长判断, 32位agent运行在64位内核上时, 超过32位的计数变小仍按重置处理
+	counterProcDev counterWidth = bits.UintSize
+)
+
+// counterDelta 计算计数器两次采集之间的增量, 按计数器位数处理回绕;
+// ok为false表示计数器被重置(驱动重载、网卡重置等), 增量不可用
+func counterDelta(prev, cur uint64, width counterWidth) (delta uint64, ok bool) {
+	if cur >= prev {
+		return cur - prev, true
+	}
+
+	if width == counter32 {
+		if prev > math.MaxUint32 {
+			return 0, false
+		}
+		delta = math.MaxUint32 - prev + cur + 1
+		if delta <= maxWrapDelta32 {
+			return delta, true
+		}
+		return 0, false
+	}
+
+	delta = math.MaxUint64 - prev + cur + 1
+	if delta <= maxWrapDelta64 {
+		return delta, true
+	}
+	return 0, false
+}
+
+// delta 计算当前计数与上次采集的增量, 按本次计数来源的位数处理回绕, 任一计数器被重置则整个周期不可用
+func (n *Ifi) delta(cur devStat) (devStat, bool) {
+	prev := n.stat()
+	d := devStat{Name: cur.Name}
+
+	p, c, ds := prev.counters(), cur.counters(), d.counters()
+	for i := range c {
+		v, ok := counterDelta(*p[i], *c[i], cur.width)
+		if !ok {
+			return devStat{}, false
+		}
+		*ds[i] = v
+	}
+	return d, true
+}
+
+// stat 上次采集的计数
+func (n *Ifi) stat() devStat {
+	return devStat{
+		Name:           n.Name,
+		RecvByte:       n.RecvByte,
+		RecvPkg:        n.RecvPkg,
+		RecvErr:        n.RecvErr,
+		RecvDrop:       n.RecvDrop,
+		RecvFifo:       n.RecvFifo,
+		RecvFrame:      n.RecvFrame,
+		RecvCompressed: n.RecvCompressed,
+		RecvMulticast:  n.RecvMulticast,
+		SendByte:       n.SendByte,
+		SendPkg:        n.SendPkg,
+		SendErr:        n.SendErr,
+		SendDrop:       n.SendDrop,
+		SendFifo:       n.SendFifo,
+		SendColls:      n.SendColls,
+		SendCarrier:    n.SendCarrier,
+		SendCompressed: n.SendCompressed,
+	}
+}
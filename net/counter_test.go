@@ -0,0 +1,79 @@
+package net
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/procfs"
+)
+
+func TestCounterDelta(t *testing.T) {
+	cases := []struct {
+		name      string
+		prev, cur uint64
+		width     counterWidth
+		delta     uint64
+		ok        bool
+	}{
+		{"increase", 100, 250, counter64, 150, true},
+		{"unchanged", 100, 100, counter64, 0, true},
+		{"wrap32", math.MaxUint32 - 9, 10, counter32, 20, true},
+		{"wrap64", math.MaxUint64 - 9, 10, counter64, 20, true},
+		{"reset32", 1000000, 1500, counter32, 0, false},
+		{"reset64", 1 << 40, 1500, counter64, 0, false},
+		//64位计数器在[2^31, 2^32)之间重置不能当作32位回绕
+		{"reset64 below 2^32", 3e9, 100, counter64, 0, false},
+		{"wrap32 of 3e9", 3e9, 100, counter32, math.MaxUint32 - 3e9 + 101, true},
+		{"32bit counter above 2^32", 1 << 40, 100, counter32, 0, false},
+	}
+	for _, c := range cases {
+		delta, ok := counterDelta(c.prev, c.cur, c.width)
+		if delta != c.delta || ok != c.ok {
+			t.Errorf("%s: got (%v, %v), want (%v, %v)", c.name, delta, ok, c.delta, c.ok)
+		}
+	}
+}
+
+func TestIfiDeltaWidth(t *testing.T) {
+	ifi := &Ifi{Name: "eth0", RecvByte: math.MaxUint32 - 9}
+
+	//32位内核上/proc/net/dev的计数回绕, 不是重置
+	d, ok := ifi.delta(devStat{Name: "eth0", RecvByte: 10, width: counter32})
+	if !ok || d.RecvByte != 20 {
+		t.Fatalf("32-bit wrap: got %v, %v", d.RecvByte, ok)
+	}
+	if _, ok := ifi.delta(devStat{Name: "eth0", RecvByte: 10, width: counter64}); ok {
+		t.Fatal("64-bit counter going backwards should be a reset")
+	}
+}
+
+func TestCollectCounterReset(t *testing.T) {
+	n, now := newFixtureNetwork(t, "testdata/host/t1")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	*now = now.Add(10 * time.Second)
+	n.fs = procfs.NewFS("testdata/host/t2")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	eth0 := n.Current().IfiMap["eth0"]
+	if eth0.Resets != 1 || !eth0.LastReset.Equal(*now) {
+		t.Fatalf("reset not recorded: resets=%v last=%v", eth0.Resets, eth0.LastReset)
+	}
+	if eth0.RecvByteAvg != 0 || eth0.SendByteAvg != 0 {
+		t.Fatalf("interval with reset should be discarded, got rx=%v tx=%v", eth0.RecvByteAvg, eth0.SendByteAvg)
+	}
+	if eth0.RecvByte != 1500 {
+		t.Fatalf("counters should be rebased, got %v", eth0.RecvByte)
+	}
+
+	eth1 := n.Current().IfiMap["eth1"]
+	if eth1.Resets != 0 || eth1.RecvByteAvg != 10000 {
+		t.Fatalf("eth1 unaffected: resets=%v rx=%v", eth1.Resets, eth1.RecvByteAvg)
+	}
+}
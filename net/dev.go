@@ -0,0 +1,126 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+)
+
+// devStat /proc/net/dev 单个网卡的计数
+type devStat struct {
+	Name string
+
+	RecvByte       uint64
+	RecvPkg        uint64
+	RecvErr        uint64
+	RecvDrop       uint64
+	RecvFifo       uint64
+	RecvFrame      uint64
+	RecvCompressed uint64
+	RecvMulticast  uint64
+
+	SendByte       uint64
+	SendPkg        uint64
+	SendErr        uint64
+	SendDrop       uint64
+	SendFifo       uint64
+	SendColls      uint64
+	SendCarrier    uint64
+	SendCompressed uint64
+
+	width counterWidth //计数器位数, 由数据来源决定
+}
+
+// devCounters /proc/net/dev每行的计数器个数
+const devCounters = 16
+
+// counters 所有计数器, 顺序固定; 返回数组以免每次调用分配内存
+func (s *devStat) counters() [devCounters]*uint64 {
+	return [devCounters]*uint64{
+		&s.RecvByte, &s.RecvPkg, &s.RecvErr, &s.RecvDrop,
+		&s.RecvFifo, &s.RecvFrame, &s.RecvCompressed, &s.RecvMulticast,
+		&s.SendByte, &s.SendPkg, &s.SendErr, &s.SendDrop,
+		&s.SendFifo, &s.SendColls, &s.SendCarrier, &s.SendCompressed,
+	}
+}
+
+// parseDev 解析 /proc/net/dev, 每行只为网卡名分配内存
+func parseDev(r io.Reader) ([]devStat, error) {
+	var stats []devStat
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if stat, ok := parseDevLine(scanner.Bytes()); ok {
+			stats = append(stats, stat)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func parseDevLine(line []byte) (devStat, bool) {
+	i := bytes.IndexByte(line, ':')
+	if i < 0 {
+		return devStat{}, false
+	}
+	name := bytes.TrimSpace(line[:i])
+	if len(name) == 0 {
+		return devStat{}, false
+	}
+
+	//字段顺序与counters一致
+	stat := devStat{width: counterProcDev}
+	counters := stat.counters()
+	rest := line[i+1:]
+	for _, counter := range counters {
+		var field []byte
+		field, rest = nextField(rest)
+		if field == nil {
+			return devStat{}, false
+		}
+		*counter = parseUint(field)
+	}
+	if field, _ := nextField(rest); field != nil {
+		return devStat{}, false
+	}
+	stat.Name = string(name)
+	return stat, true
+}
+
+// nextField 返回b中下一个以空白分隔的字段和剩余部分, 没有字段时返回nil
+func nextField(b []byte) (field, rest []byte) {
+	start := 0
+	for start < len(b) && isSpace(b[start]) {
+		start++
+	}
+	if start == len(b) {
+		return nil, nil
+	}
+	end := start
+	for end < len(b) && !isSpace(b[end]) {
+		end++
+	}
+	return b[start:end], b[end:]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// parseUint 解析十进制计数, 非法字符或溢出按0处理, 与忽略strconv.ParseUint的错误一致
+func parseUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0
+		}
+		d := uint64(c - '0')
+		if v > (math.MaxUint64-d)/10 {
+			return 0
+		}
+		v = v*10 + d
+	}
+	return v
+}
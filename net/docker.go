@@ -0,0 +1,81 @@
+package net
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultDockerSocket = "/var/run/docker.sock"
+
+// DockerResolver 通过docker的unix socket查询容器名, 结果按容器ID缓存, 容器消失后删除
+type DockerResolver struct {
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[string]*Container
+}
+
+// NewDockerResolver socket为空时使用DefaultDockerSocket
+func NewDockerResolver(socket string) *DockerResolver {
+	if socket == "" {
+		socket = DefaultDockerSocket
+	}
+	return &DockerResolver{
+		client: &http.Client{
+			Timeout: 2 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+		cache: make(map[string]*Container),
+	}
+}
+
+func (r *DockerResolver) ContainerByID(id string) (*Container, error) {
+	r.mu.Lock()
+	c, exists := r.cache[id]
+	r.mu.Unlock()
+	if exists {
+		return c, nil
+	}
+
+	resp, err := r.client.Get("http://docker/containers/" + id + "/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrContainerNotFound
+	}
+
+	var inspect struct {
+		Name string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return nil, err
+	}
+
+	c = &Container{ID: id, Name: strings.TrimPrefix(inspect.Name, "/"), Runtime: RuntimeDocker}
+	r.mu.Lock()
+	r.cache[id] = c
+	r.mu.Unlock()
+	return c, nil
+}
+
+// retain 删除已经不存在的容器的缓存
+func (r *DockerResolver) retain(ids map[string]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id := range r.cache {
+		if !ids[id] {
+			delete(r.cache, id)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package net
+
+import (
+	"errors"
+)
+
+var ErrDriverStatsNotFound = errors.New("driver stats not found")
+
+// DriverStat 网卡驱动的一项统计, 即ethtool -S的一行
+type DriverStat struct {
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+// DriverStatsSource 网卡驱动统计的来源
+type DriverStatsSource interface {
+	DriverStats(name string) ([]DriverStat, error)
+}
+
+// EthtoolStats 通过ETHTOOL_GSTATS ioctl读取本机网卡的驱动统计, 统计项名称按网卡缓存
+type EthtoolStats struct{}
+
+func (EthtoolStats) DriverStats(name string) ([]DriverStat, error) {
+	return ethtoolStats(name)
+}
+
+// StaticDriverStats 固定的驱动统计, 用于测试和回放现场数据
+type StaticDriverStats map[string][]DriverStat
+
+func (s StaticDriverStats) DriverStats(name string) ([]DriverStat, error) {
+	stats, exists := s[name]
+	if !exists {
+		return nil, ErrDriverStatsNotFound
+	}
+	return stats, nil
+}
+
+const (
+	DriverRecvMissed   = "rx_missed_errors"   //网卡接收FIFO满, 包未进入主机内存
+	DriverRecvNoBuffer = "rx_no_buffer_count" //接收ring没有可用描述符
+	DriverRecvCRC      = "rx_crc_errors"      //接收CRC校验错误
+)
+
+// driverCounterAliases 归一化计数在各驱动中的统计项名称, 按优先级排列, 取第一个存在的
+var driverCounterAliases = []struct {
+	name    string
+	aliases []string
+}{
+	{name: DriverRecvMissed, aliases: []string{
+		"rx_missed_errors", //e1000e/igb/ixgbe/ice/i40e
+		"rx_discards_phy",  //mlx5
+		"rx_missed",
+	}},
+	{name: DriverRecvNoBuffer, aliases: []string{
+		"rx_no_buffer_count",  //e1000e/igb/ixgbe
+		"rx_out_of_buffer",    //mlx5
+		"rx_no_dma_resources", //ixgbe
+		"port.rx_no_buffer_count",
+	}},
+	{name: DriverRecvCRC, aliases: []string{
+		"rx_crc_errors",      //e1000e/igb/ixgbe/ice
+		"port.rx_crc_errors", //i40e
+		"rx_crc_errors_phy",  //mlx5
+		"rx_fcs_errors",      //tg3
+		"rx_fcs_err_frames",  //bnxt_en
+	}},
+}
+
+// DriverCounter 跨驱动归一化的驱动计数, 驱动不提供的计数不出现
+type DriverCounter struct {
+	Name   string  `json:"name"`   //归一化名称, 如rx_missed_errors
+	Source string  `json:"source"` //驱动中的统计项名称
+	Value  uint64  `json:"value"`  //累计值
+	Avg    float64 `json:"avg"`    //一个周期平均每秒增量
+}
+
+// normalizeDriverStats 从驱动统计中取出归一化计数, prev为上次的计数, interval为距上次采集的秒数
+func normalizeDriverStats(stats []DriverStat, prev []DriverCounter, interval float64) []DriverCounter {
+	values := make(map[string]uint64, len(stats))
+	for _, s := range stats {
+		values[s.Name] = s.Value
+	}
+
+	var counters []DriverCounter
+	for _, c := range driverCounterAliases {
+		for _, alias := range c.aliases {
+			value, exists := values[alias]
+			if !exists {
+				continue
+			}
+			counter := DriverCounter{Name: c.name, Source: alias, Value: value}
+			for _, p := range prev {
+				if p.Name != c.name || p.Source != alias || interval <= 0 {
+					continue
+				}
+				if d, ok := counterDelta(p.Value, value, counter64); ok {
+					counter.Avg = float64(d) / interval
+				}
+			}
+			counters = append(counters, counter)
+			break
+		}
+	}
+	return counters
+}
+
+// filterDriverStats 按include过滤驱动统计, 匹配规则同IgnoreEth; include为空时保留全部
+func filterDriverStats(stats []DriverStat, include namePatterns) []DriverStat {
+	if len(include) == 0 {
+		return stats
+	}
+	var kept []DriverStat
+	for _, s := range stats {
+		if include.match(s.Name) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,123 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNormalizeDriverStats(t *testing.T) {
+	//ixgbe同时有rx_no_buffer_count和rx_no_dma_resources, 取优先的一个
+	ixgbe := []DriverStat{
+		{Name: "rx_packets", Value: 100},
+		{Name: "rx_missed_errors", Value: 10},
+		{Name: "rx_no_dma_resources", Value: 7},
+		{Name: "rx_no_buffer_count", Value: 3},
+		{Name: "rx_crc_errors", Value: 1},
+	}
+	got := normalizeDriverStats(ixgbe, nil, 0)
+	want := []DriverCounter{
+		{Name: DriverRecvMissed, Source: "rx_missed_errors", Value: 10},
+		{Name: DriverRecvNoBuffer, Source: "rx_no_buffer_count", Value: 3},
+		{Name: DriverRecvCRC, Source: "rx_crc_errors", Value: 1},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("ixgbe = %+v", got)
+	}
+
+	mlx5 := []DriverStat{
+		{Name: "rx_out_of_buffer", Value: 50},
+		{Name: "rx_crc_errors_phy", Value: 2},
+	}
+	prev := []DriverCounter{
+		{Name: DriverRecvNoBuffer, Source: "rx_out_of_buffer", Value: 30},
+		{Name: DriverRecvCRC, Source: "rx_crc_errors", Value: 0},
+	}
+	got = normalizeDriverStats(mlx5, prev, 10)
+	want = []DriverCounter{
+		{Name: DriverRecvNoBuffer, Source: "rx_out_of_buffer", Value: 50, Avg: 2},
+		{Name: DriverRecvCRC, Source: "rx_crc_errors_phy", Value: 2},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("mlx5 = %+v", got)
+	}
+
+	if got := normalizeDriverStats([]DriverStat{{Name: "rx_kicks", Value: 3}}, nil, 10); len(got) != 0 {
+		t.Fatalf("virtio = %+v", got)
+	}
+}
+
+func TestFilterDriverStats(t *testing.T) {
+	stats := []DriverStat{
+		{Name: "rx_queue_0_packets"},
+		{Name: "rx_queue_0_bytes"},
+		{Name: "rx_missed_errors"},
+		{Name: "tx_timeout_count"},
+	}
+	if got := filterDriverStats(stats, nil); len(got) != len(stats) {
+		t.Fatalf("empty include kept %v", got)
+	}
+	got := filterDriverStats(stats, namePatterns{"rx_queue_*_packets", "rx_missed"})
+	if len(got) != 2 || got[0].Name != "rx_queue_0_packets" || got[1].Name != "rx_missed_errors" {
+		t.Fatalf("filtered = %v", got)
+	}
+}
+
+func TestCollectDriverCounters(t *testing.T) {
+	root := t.TempDir()
+	writeTopologyDev(t, root, map[string]uint64{})
+
+	r := StaticResolver{}
+	for i, name := range []string{"eth0", "eth1"} {
+		r[name] = &Link{Index: i + 2, Name: name, Addrs: []net.Addr{mustCIDR(t, fmt.Sprintf("203.0.113.%d/24", i+10))}}
+	}
+	stats := StaticDriverStats{
+		"eth0": {{Name: "rx_missed_errors", Value: 100}, {Name: "rx_crc_errors", Value: 5}, {Name: "tx_restart_queue", Value: 1}},
+	}
+
+	now := time.Unix(1600000000, 0)
+	n := NewNetwork([]string{}, []string{"lo"}, []string{}, []string{},
+		WithRoot(root), WithResolver(r), WithDriverStats(stats), WithDriverCounters("rx_"))
+	n.now = func() time.Time { return now }
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	stats["eth0"] = []DriverStat{{Name: "rx_missed_errors", Value: 600}, {Name: "rx_crc_errors", Value: 5}, {Name: "tx_restart_queue", Value: 2}}
+	now = now.Add(10 * time.Second)
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s := n.Current()
+
+	eth0 := s.IfiMap["eth0"]
+	if len(eth0.DriverStats) != 2 || eth0.DriverStats[0].Name != "rx_missed_errors" {
+		t.Fatalf("driver stats = %+v", eth0.DriverStats)
+	}
+	want := []DriverCounter{
+		{Name: DriverRecvMissed, Source: "rx_missed_errors", Value: 600, Avg: 50},
+		{Name: DriverRecvCRC, Source: "rx_crc_errors", Value: 5},
+	}
+	if fmt.Sprint(eth0.DriverCounters) != fmt.Sprint(want) {
+		t.Fatalf("driver counters = %+v", eth0.DriverCounters)
+	}
+	//驱动不提供统计的网卡没有计数
+	if eth1 := s.IfiMap["eth1"]; eth1.DriverStats != nil || eth1.DriverCounters != nil {
+		t.Fatalf("eth1 = %+v %+v", eth1.DriverStats, eth1.DriverCounters)
+	}
+
+	var found bool
+	for _, sample := range s.Samples() {
+		if sample.Name == "net_driver_counter_avg" && sample.Labels["iface"] == "eth0" && sample.Labels["counter"] == DriverRecvMissed {
+			found = sample.Value == 50
+		}
+		if sample.Name == "net_driver_stat" && sample.Labels["stat"] == "tx_restart_queue" {
+			t.Errorf("excluded stat exported: %+v", sample)
+		}
+	}
+	if !found {
+		t.Fatal("net_driver_counter_avg not exported")
+	}
+}
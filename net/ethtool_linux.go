@@ -0,0 +1,216 @@
+package net
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	siocEthtool     = 0x8946 //SIOCETHTOOL
+	ethtoolGSet     = 0x1    //ETHTOOL_GSET
+	ethtoolGDrvInfo = 0x3    //ETHTOOL_GDRVINFO
+	ethtoolGStrings = 0x1b   //ETHTOOL_GSTRINGS
+	ethtoolGStats   = 0x1d   //ETHTOOL_GSTATS
+
+	ethSSStats      = 1  //ETH_SS_STATS
+	ethGStringLen   = 32 //ETH_GSTRING_LEN
+	ethtoolMaxStats = 1 << 16
+
+	ifNameSize = 16
+)
+
+// ifreq 只使用ifr_data的struct ifreq
+type ifreq struct {
+	name [ifNameSize]byte
+	data unsafe.Pointer
+	_    [16]byte
+}
+
+// ethtoolCmd struct ethtool_cmd
+type ethtoolCmd struct {
+	cmd           uint32
+	supported     uint32
+	advertising   uint32
+	speed         uint16
+	duplex        uint8
+	port          uint8
+	phyAddress    uint8
+	transceiver   uint8
+	autoneg       uint8
+	mdioSupport   uint8
+	maxtxpkt      uint32
+	maxrxpkt      uint32
+	speedHi       uint16
+	ethTpMdix     uint8
+	ethTpMdixCtrl uint8
+	lpAdvertising uint32
+	reserved      [2]uint32
+}
+
+// ethtoolDrvInfo struct ethtool_drvinfo
+type ethtoolDrvInfo struct {
+	cmd         uint32
+	driver      [32]byte
+	version     [32]byte
+	fwVersion   [32]byte
+	busInfo     [32]byte
+	eromVersion [32]byte
+	reserved2   [12]byte
+	nPrivFlags  uint32
+	nStats      uint32
+	testinfoLen uint32
+	eedumpLen   uint32
+	regdumpLen  uint32
+}
+
+// ethtool 对网卡执行一次SIOCETHTOOL ioctl, data的第一个字段必须是ethtool命令字
+func ethtool(name string, data unsafe.Pointer) error {
+	if len(name) >= ifNameSize {
+		return syscall.EINVAL
+	}
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	var ifr ifreq
+	copy(ifr.name[:], name)
+	ifr.data = data
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), siocEthtool, uintptr(unsafe.Pointer(&ifr)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ethtoolLinkMode 通过ETHTOOL_GSET获取速率、双工、自协商和端口类型
+func ethtoolLinkMode(name string) (linkMode, error) {
+	cmd := ethtoolCmd{cmd: ethtoolGSet}
+	if err := ethtool(name, unsafe.Pointer(&cmd)); err != nil {
+		return linkMode{}, err
+	}
+
+	mode := linkMode{Duplex: duplexUnknown, Autoneg: cmd.autoneg == 1, Port: portName(cmd.port)}
+	speed := uint32(cmd.speedHi)<<16 | uint32(cmd.speed)
+	if speed != 0 && speed != 0xffff && speed != 0xffffffff {
+		mode.Speed = float64(speed)
+	}
+	switch cmd.duplex {
+	case 0x00:
+		mode.Duplex = duplexHalf
+	case 0x01:
+		mode.Duplex = duplexFull
+	}
+	return mode, nil
+}
+
+// ethtoolDriverInfo 通过ETHTOOL_GDRVINFO获取驱动名和总线地址
+func ethtoolDriverInfo(name string) (driverInfo, error) {
+	info := ethtoolDrvInfo{cmd: ethtoolGDrvInfo}
+	if err := ethtool(name, unsafe.Pointer(&info)); err != nil {
+		return driverInfo{}, err
+	}
+	return driverInfo{Driver: cString(info.driver[:]), BusInfo: cString(info.busInfo[:])}, nil
+}
+
+// ethtoolStrings 按网卡缓存的驱动统计项名称, 统计项个数变化(如驱动重新加载)时重新获取
+var ethtoolStrings = struct {
+	sync.Mutex
+	names map[string][]string
+}{names: make(map[string][]string)}
+
+// ethtoolStats 通过ETHTOOL_GDRVINFO获取统计项个数, ETHTOOL_GSTRINGS获取名称, ETHTOOL_GSTATS获取取值
+func ethtoolStats(name string) ([]DriverStat, error) {
+	info := ethtoolDrvInfo{cmd: ethtoolGDrvInfo}
+	if err := ethtool(name, unsafe.Pointer(&info)); err != nil {
+		return nil, err
+	}
+	count := int(info.nStats)
+	if count == 0 {
+		return nil, nil
+	}
+	if count > ethtoolMaxStats {
+		return nil, syscall.EINVAL
+	}
+
+	names, err := ethtoolStatNames(name, count)
+	if err != nil {
+		return nil, err
+	}
+
+	//struct ethtool_stats
+	buf := make([]uint64, 1+count)
+	hdr := (*[2]uint32)(unsafe.Pointer(&buf[0]))
+	hdr[0], hdr[1] = ethtoolGStats, uint32(count)
+	if err := ethtool(name, unsafe.Pointer(&buf[0])); err != nil {
+		return nil, err
+	}
+	//统计项个数在两次ioctl之间变化时以较小者为准
+	if n := int(hdr[1]); n < count {
+		count = n
+	}
+
+	stats := make([]DriverStat, count)
+	for i := range stats {
+		stats[i] = DriverStat{Name: names[i], Value: buf[1+i]}
+	}
+	return stats, nil
+}
+
+func ethtoolStatNames(name string, count int) ([]string, error) {
+	ethtoolStrings.Lock()
+	names, exists := ethtoolStrings.names[name]
+	ethtoolStrings.Unlock()
+	if exists && len(names) == count {
+		return names, nil
+	}
+
+	//struct ethtool_gstrings, 按uint32分配以保证对齐
+	buf := make([]uint32, 3+(count*ethGStringLen+3)/4)
+	buf[0], buf[1], buf[2] = ethtoolGStrings, ethSSStats, uint32(count)
+	if err := ethtool(name, unsafe.Pointer(&buf[0])); err != nil {
+		return nil, err
+	}
+	size := count * ethGStringLen
+	data := (*[ethtoolMaxStats * ethGStringLen]byte)(unsafe.Pointer(&buf[3]))[:size:size]
+	names = make([]string, count)
+	for i := range names {
+		names[i] = cString(data[i*ethGStringLen : (i+1)*ethGStringLen])
+	}
+
+	ethtoolStrings.Lock()
+	ethtoolStrings.names[name] = names
+	ethtoolStrings.Unlock()
+	return names, nil
+}
+
+// forgetEthtoolStats 网卡消失后丢弃缓存的统计项名称
+func forgetEthtoolStats(name string) {
+	ethtoolStrings.Lock()
+	delete(ethtoolStrings.names, name)
+	ethtoolStrings.Unlock()
+}
+
+func portName(port uint8) string {
+	switch port {
+	case 0x00:
+		return "TP"
+	case 0x01:
+		return "AUI"
+	case 0x02:
+		return "BNC"
+	case 0x03:
+		return "MII"
+	case 0x04:
+		return "FIBRE"
+	case 0x05:
+		return "DA"
+	case 0xef:
+		return "NONE"
+	}
+	return "OTHER"
+}
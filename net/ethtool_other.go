@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+package net
+
+import (
+	"errors"
+)
+
+var errEthtoolUnsupported = errors.New("ethtool is only supported on linux")
+
+func ethtoolLinkMode(name string) (linkMode, error) {
+	return linkMode{}, errEthtoolUnsupported
+}
+
+func ethtoolDriverInfo(name string) (driverInfo, error) {
+	return driverInfo{}, errEthtoolUnsupported
+}
+
+func ethtoolStats(name string) ([]DriverStat, error) {
+	return nil, errEthtoolUnsupported
+}
+
+func forgetEthtoolStats(name string) {}
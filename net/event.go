@@ -0,0 +1,154 @@
+package net
+
+import (
+	"sync"
+	"time"
+)
+
+type EventType int
+
+const (
+	EventIfiAdded     EventType = iota + 1 //网卡加入监控
+	EventIfiRemoved                        //网卡消失或不再监控
+	EventLinkUp                            //运行状态变为up
+	EventLinkDown                          //运行状态由up变为其他状态
+	EventSpeedChanged                      //速率变化
+	EventAddrAdded                         //增加地址
+	EventAddrRemoved                       //删除地址
+	EventIfiCreated                        //内核创建网卡, 只由Watch发布, 不论是否监控
+	EventIfiDeleted                        //内核删除网卡, 只由Watch发布, 不论是否监控
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventIfiAdded:
+		return "interface added"
+	case EventIfiRemoved:
+		return "interface removed"
+	case EventLinkUp:
+		return "link up"
+	case EventLinkDown:
+		return "link down"
+	case EventSpeedChanged:
+		return "speed changed"
+	case EventAddrAdded:
+		return "address added"
+	case EventAddrRemoved:
+		return "address removed"
+	case EventIfiCreated:
+		return "interface created"
+	case EventIfiDeleted:
+		return "interface deleted"
+	}
+	return "unknown"
+}
+
+// Event 网卡事件
+type Event struct {
+	Type EventType
+	Name string    //网卡名, 与Ifi.Key()相同
+	Ifi  Ifi       //事件发生时网卡的副本, 内核创建删除的未监控网卡只有Name和Index
+	Time time.Time //事件时间
+
+	Addr          Addr    //EventAddrAdded/EventAddrRemoved的地址
+	PrevOperState string  //EventLinkUp/EventLinkDown之前的运行状态
+	PrevSpeed     float64 //EventSpeedChanged之前的速率(Mb/s)
+}
+
+// changeEvents 比较同一网卡两次采集之间的运行状态、速率和地址
+func changeEvents(key string, prev, cur *Ifi, now time.Time) []Event {
+	var events []Event
+	event := func(typ EventType) Event {
+		return Event{Type: typ, Name: key, Ifi: *cur, Time: now}
+	}
+
+	if prev.OperState != cur.OperState && prev.OperState != "" && cur.OperState != "" {
+		switch {
+		case cur.OperState == "up":
+			e := event(EventLinkUp)
+			e.PrevOperState = prev.OperState
+			events = append(events, e)
+		case prev.OperState == "up":
+			e := event(EventLinkDown)
+			e.PrevOperState = prev.OperState
+			events = append(events, e)
+		}
+	}
+	if prev.Speed != cur.Speed {
+		e := event(EventSpeedChanged)
+		e.PrevSpeed = prev.Speed
+		events = append(events, e)
+	}
+
+	for _, a := range cur.Addrs {
+		if !containsAddr(prev.Addrs, a) {
+			e := event(EventAddrAdded)
+			e.Addr = a
+			events = append(events, e)
+		}
+	}
+	for _, a := range prev.Addrs {
+		if !containsAddr(cur.Addrs, a) {
+			e := event(EventAddrRemoved)
+			e.Addr = a
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func containsAddr(addrs []Addr, a Addr) bool {
+	for _, b := range addrs {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
+
+// eventBus 事件分发, 订阅者处理不及时时丢弃事件, 不阻塞采集
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func (b *eventBus) subscribe(buffer int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs == nil {
+		b.subs = make(map[chan Event]struct{})
+	}
+	ch := make(chan Event, buffer)
+	b.subs[ch] = struct{}{}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs, ch)
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+func (b *eventBus) publish(events ...Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, e := range events {
+		for ch := range b.subs {
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+	}
+}
+
+// Subscribe 订阅网卡事件, buffer为通道缓冲大小; 调用返回的cancel取消订阅并关闭通道
+func (n *NetWork) Subscribe(buffer int) (<-chan Event, func()) {
+	return n.events.subscribe(buffer)
+}
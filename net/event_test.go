@@ -0,0 +1,66 @@
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/procfs"
+)
+
+func TestEvictVanishedIfi(t *testing.T) {
+	n, now := newFixtureNetwork(t, "testdata/host/t0")
+	events, cancel := n.Subscribe(8)
+	defer cancel()
+
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"eth0", "eth1"} {
+		e := <-events
+		if e.Type != EventIfiAdded || e.Name != want {
+			t.Fatalf("got %v %v, want added %v", e.Type, e.Name, want)
+		}
+	}
+
+	*now = now.Add(10 * time.Second)
+	n.fs = procfs.NewFS("testdata/host/t3")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-events:
+		if e.Type != EventIfiRemoved || e.Name != "eth1" || e.Ifi.RecvByte != 200000 {
+			t.Fatalf("got %v %v %+v, want eth1 removed", e.Type, e.Name, e.Ifi)
+		}
+	default:
+		t.Fatal("no removed event")
+	}
+
+	snap := n.Current()
+	if len(snap.IfiNames) != 1 || snap.IfiNames[0] != "eth0" {
+		t.Fatalf("got interfaces %v", snap.IfiNames)
+	}
+	if snap.InRecvByteAvg != 0 {
+		t.Fatalf("removed interface still counted: %v", snap.InRecvByteAvg)
+	}
+	if _, exists := n.ifis["eth1"]; exists {
+		t.Fatal("eth1 still in working state")
+	}
+}
+
+func TestSubscribeCancel(t *testing.T) {
+	n, _ := newFixtureNetwork(t, "testdata/host/t0")
+	events, cancel := n.Subscribe(0)
+	cancel()
+	cancel()
+
+	if _, ok := <-events; ok {
+		t.Fatal("channel should be closed")
+	}
+	//无订阅者和缓冲已满时都不能阻塞采集
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
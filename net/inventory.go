@@ -0,0 +1,302 @@
+package net
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// IfiStat 网卡清单条目
+type IfiStat struct {
+	Name  string `json:"name"`    //网卡接口
+	Index int    `json:"ifindex"` //内核ifindex
+
+	Netns      string `json:"netns,omitempty"` //网络命名空间, agent所在的命名空间为空
+	NetnsInode uint64 `json:"netns_inode"`     //网络命名空间inode
+
+	ContainerID   string `json:"container_id,omitempty"`   //所属容器ID
+	ContainerName string `json:"container_name,omitempty"` //所属容器名
+
+	Master  string    `json:"master,omitempty"` //所属的bond/team/bridge
+	Lower   string    `json:"lower,omitempty"`  //VLAN的父网卡
+	Bond    *BondInfo `json:"bond,omitempty"`   //bond状态
+	Counted bool      `json:"counted"`          //是否计入整机统计
+
+	Ip    string  `json:"ip"`    //网卡主IP
+	Addrs []Addr  `json:"addrs"` //网卡所有地址
+	Speed float64 `json:"speed"` //网卡速率(Mb/s)
+
+	RecvUseRate float64 `json:"recv_use_rate"` //接收带宽使用率(%)
+	SendUseRate float64 `json:"send_use_rate"` //发送带宽使用率(%)
+
+	Duplex  string `json:"duplex"`  //双工模式
+	Autoneg bool   `json:"autoneg"` //是否自协商
+	Port    string `json:"port"`    //端口类型
+
+	MTU            int    `json:"mtu"`             //MTU
+	MAC            string `json:"mac"`             //硬件地址
+	OperState      string `json:"operstate"`       //运行状态
+	CarrierChanges uint64 `json:"carrier_changes"` //链路up/down累计次数
+	CarrierFlaps   uint64 `json:"carrier_flaps"`   //周期内链路up/down次数
+	Driver         string `json:"driver"`          //驱动名
+	BusInfo        string `json:"bus_info"`        //总线地址
+	Type           string `json:"type"`            //网卡类型
+
+	BandwidthLimit  int     `json:"bandwidth_limit"`   //0不限制, 1被限制
+	RecvLimit       float64 `json:"recv_limit"`        //接收方向限速(Mb/s)
+	SendLimit       float64 `json:"send_limit"`        //发送方向限速(Mb/s)
+	RecvLimitSource string  `json:"recv_limit_source"` //接收方向限速来源
+	SendLimitSource string  `json:"send_limit_source"` //发送方向限速来源
+
+	RecvByte       uint64 `json:"recv_byte"`       //接收字节数
+	RecvPkg        uint64 `json:"recv_pkg"`        //接收包数
+	RecvErr        uint64 `json:"recv_err"`        //接收错误包数
+	RecvDrop       uint64 `json:"recv_drop"`       //接收丢包包数
+	RecvFifo       uint64 `json:"recv_fifo"`       //接收FIFO溢出数
+	RecvFrame      uint64 `json:"recv_frame"`      //接收帧错误数
+	RecvCompressed uint64 `json:"recv_compressed"` //接收压缩包数
+	RecvMulticast  uint64 `json:"recv_multicast"`  //接收组播包数
+	SendByte       uint64 `json:"send_byte"`       //发送字节数
+	SendPkg        uint64 `json:"send_pkg"`        //发送包数
+	SendErr        uint64 `json:"send_err"`        //发送错误包数
+	SendDrop       uint64 `json:"send_drop"`       //发送丢包包数
+	SendFifo       uint64 `json:"send_fifo"`       //发送FIFO溢出数
+	SendColls      uint64 `json:"send_colls"`      //发送冲突数
+	SendCarrier    uint64 `json:"send_carrier"`    //发送载波错误数
+	SendCompressed uint64 `json:"send_compressed"` //发送压缩包数
+
+	RecvByteAvg    float64 `json:"recv_byte_avg"`     //平均每秒接收字节数
+	RecvPkgAvg     float64 `json:"recv_pkg_avg"`      //平均每秒接收包数
+	RecvErrPkgAvg  float64 `json:"recv_err_pkg_avg"`  //平均每秒收包错误数
+	RecvDropPkgAvg float64 `json:"recv_drop_pkg_avg"` //平均每秒收包丢包数
+	RecvErrRate    float64 `json:"recv_err_rate"`     //收包错误率
+	RecvDropRate   float64 `json:"recv_drop_rate"`    //收包丢包率
+
+	RecvFifoAvg       float64 `json:"recv_fifo_avg"`       //平均每秒接收FIFO溢出数
+	RecvFrameAvg      float64 `json:"recv_frame_avg"`      //平均每秒接收帧错误数
+	RecvCompressedAvg float64 `json:"recv_compressed_avg"` //平均每秒接收压缩包数
+	RecvMulticastAvg  float64 `json:"recv_multicast_avg"`  //平均每秒接收组播包数
+
+	SendByteAvg    float64 `json:"send_byte_avg"`     //平均每秒发送字节数
+	SendPkgAvg     float64 `json:"send_pkg_avg"`      //平均每秒发送包数
+	SendErrPkgAvg  float64 `json:"send_err_pkg_avg"`  //平均每秒发包错误数
+	SendDropPkgAvg float64 `json:"send_drop_pkg_avg"` //平均每秒发包丢包数
+	SendErrRate    float64 `json:"send_err_rate"`     //发包错误率
+	SendDropRate   float64 `json:"send_drop_rate"`    //发包丢包率
+
+	SendFifoAvg       float64 `json:"send_fifo_avg"`       //平均每秒发送FIFO溢出数
+	SendCollsAvg      float64 `json:"send_colls_avg"`      //平均每秒发送冲突数
+	SendCarrierAvg    float64 `json:"send_carrier_avg"`    //平均每秒发送载波错误数
+	SendCompressedAvg float64 `json:"send_compressed_avg"` //平均每秒发送压缩包数
+
+	Queues             []QueueStat `json:"queues,omitempty"`     //收发队列
+	RecvQueueImbalance float64     `json:"recv_queue_imbalance"` //接收队列不均衡度
+	SendQueueImbalance float64     `json:"send_queue_imbalance"` //发送队列不均衡度
+
+	DriverStats    []DriverStat    `json:"driver_stats,omitempty"`    //驱动统计
+	DriverCounters []DriverCounter `json:"driver_counters,omitempty"` //归一化的驱动计数
+}
+
+func newIfiStat(ifi *Ifi) IfiStat {
+	return IfiStat{
+		Name:  ifi.Name,
+		Index: ifi.Index,
+
+		Netns:      ifi.Netns,
+		NetnsInode: ifi.NetnsInode,
+
+		ContainerID:   ifi.ContainerID,
+		ContainerName: ifi.ContainerName,
+
+		Master:  ifi.Master,
+		Lower:   ifi.Lower,
+		Bond:    ifi.Bond,
+		Counted: ifi.Counted,
+
+		Ip:    ifi.Ip,
+		Addrs: ifi.Addrs,
+		Speed: ifi.Speed,
+
+		RecvUseRate: ifi.RecvUseRate,
+		SendUseRate: ifi.SendUseRate,
+
+		Duplex:  ifi.Duplex,
+		Autoneg: ifi.Autoneg,
+		Port:    ifi.Port,
+
+		MTU:            ifi.MTU,
+		MAC:            ifi.MAC,
+		OperState:      ifi.OperState,
+		CarrierChanges: ifi.CarrierChanges,
+		CarrierFlaps:   ifi.CarrierFlaps,
+		Driver:         ifi.Driver,
+		BusInfo:        ifi.BusInfo,
+		Type:           ifi.Type,
+
+		BandwidthLimit:  ifi.BandwidthLimit,
+		RecvLimit:       ifi.RecvLimit,
+		SendLimit:       ifi.SendLimit,
+		RecvLimitSource: ifi.RecvLimitSource,
+		SendLimitSource: ifi.SendLimitSource,
+
+		RecvByte:       ifi.RecvByte,
+		RecvPkg:        ifi.RecvPkg,
+		RecvErr:        ifi.RecvErr,
+		RecvDrop:       ifi.RecvDrop,
+		RecvFifo:       ifi.RecvFifo,
+		RecvFrame:      ifi.RecvFrame,
+		RecvCompressed: ifi.RecvCompressed,
+		RecvMulticast:  ifi.RecvMulticast,
+		SendByte:       ifi.SendByte,
+		SendPkg:        ifi.SendPkg,
+		SendErr:        ifi.SendErr,
+		SendDrop:       ifi.SendDrop,
+		SendFifo:       ifi.SendFifo,
+		SendColls:      ifi.SendColls,
+		SendCarrier:    ifi.SendCarrier,
+		SendCompressed: ifi.SendCompressed,
+
+		RecvByteAvg:    ifi.RecvByteAvg,
+		RecvPkgAvg:     ifi.RecvPkgAvg,
+		RecvErrPkgAvg:  ifi.RecvErrPkgAvg,
+		RecvDropPkgAvg: ifi.RecvDropPkgAvg,
+		RecvErrRate:    ifi.RecvErrRate,
+		RecvDropRate:   ifi.RecvDropRate,
+
+		RecvFifoAvg:       ifi.RecvFifoAvg,
+		RecvFrameAvg:      ifi.RecvFrameAvg,
+		RecvCompressedAvg: ifi.RecvCompressedAvg,
+		RecvMulticastAvg:  ifi.RecvMulticastAvg,
+
+		SendByteAvg:    ifi.SendByteAvg,
+		SendPkgAvg:     ifi.SendPkgAvg,
+		SendErrPkgAvg:  ifi.SendErrPkgAvg,
+		SendDropPkgAvg: ifi.SendDropPkgAvg,
+		SendErrRate:    ifi.SendErrRate,
+		SendDropRate:   ifi.SendDropRate,
+
+		SendFifoAvg:       ifi.SendFifoAvg,
+		SendCollsAvg:      ifi.SendCollsAvg,
+		SendCarrierAvg:    ifi.SendCarrierAvg,
+		SendCompressedAvg: ifi.SendCompressedAvg,
+
+		Queues:             ifi.Queues,
+		RecvQueueImbalance: ifi.RecvQueueImbalance,
+		SendQueueImbalance: ifi.SendQueueImbalance,
+
+		DriverStats:    ifi.DriverStats,
+		DriverCounters: ifi.DriverCounters,
+	}
+}
+
+// Inventory 按IfiNames顺序返回所有网卡的清单
+func (s *Snapshot) Inventory() []IfiStat {
+	inv := make([]IfiStat, 0, len(s.IfiNames))
+	for _, name := range s.IfiNames {
+		if ifi, exists := s.IfiMap[name]; exists {
+			inv = append(inv, newIfiStat(ifi))
+		}
+	}
+	return inv
+}
+
+// Encoder 网卡清单编码
+type Encoder interface {
+	Encode(w io.Writer, inv []IfiStat) error
+}
+
+// JSONEncoder 编码为JSON数组
+type JSONEncoder struct {
+	Indent bool //是否缩进
+}
+
+func (e JSONEncoder) Encode(w io.Writer, inv []IfiStat) error {
+	enc := json.NewEncoder(w)
+	if e.Indent {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(inv)
+}
+
+// TextEncoder 编码为对齐的文本表格
+type TextEncoder struct{}
+
+func (TextEncoder) Encode(w io.Writer, inv []IfiStat) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tNETNS\tCONTAINER\tIFINDEX\tTYPE\tSTATE\tMTU\tIP\tSPEED(Mb/s)\tDUPLEX\tRX(byte/s)\tTX(byte/s)\tRX(pkg/s)\tTX(pkg/s)\tRX_ERR\tRX_DROP\tTX_ERR\tTX_DROP")
+	for _, stat := range inv {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%d\t%s\t%.0f\t%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.4f\t%.4f\t%.4f\t%.4f\n",
+			stat.Name, netnsColumn(stat.Netns), containerColumn(stat), stat.Index, stat.Type, stat.OperState, stat.MTU, stat.Ip, stat.Speed, stat.Duplex,
+			stat.RecvByteAvg, stat.SendByteAvg, stat.RecvPkgAvg, stat.SendPkgAvg,
+			stat.RecvErrRate, stat.RecvDropRate, stat.SendErrRate, stat.SendDropRate)
+	}
+	return tw.Flush()
+}
+
+func netnsColumn(netns string) string {
+	if netns == "" {
+		return "-"
+	}
+	return netns
+}
+
+func containerColumn(stat IfiStat) string {
+	switch {
+	case stat.ContainerName != "":
+		return stat.ContainerName
+	case len(stat.ContainerID) > 12:
+		return stat.ContainerID[:12]
+	case stat.ContainerID != "":
+		return stat.ContainerID
+	}
+	return "-"
+}
+
+// LegacyByteEncoder 旧的收发字节数格式: ip=name=(rx|tx)$, 只输出agent所在命名空间的网卡, 不含容器的veth
+type LegacyByteEncoder struct{}
+
+func (LegacyByteEncoder) Encode(w io.Writer, inv []IfiStat) error {
+	for _, stat := range inv {
+		if stat.Netns != "" || stat.ContainerID != "" {
+			continue
+		}
+		_, err := io.WriteString(w, stat.Ip+"="+stat.Name+"=("+strconv.FormatFloat(stat.RecvByteAvg, 'f', 0, 64)+"|"+
+			strconv.FormatFloat(stat.SendByteAvg, 'f', 0, 64)+")$")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LegacyModelEncoder 旧的网卡型号带宽格式: name|ip|speed$, 只输出agent所在命名空间的网卡, 不含容器的veth
+type LegacyModelEncoder struct{}
+
+func (LegacyModelEncoder) Encode(w io.Writer, inv []IfiStat) error {
+	for _, stat := range inv {
+		if stat.Netns != "" || stat.ContainerID != "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%v|%v|%v$", stat.Name, stat.Ip, stat.Speed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encode 用指定编码输出最近一次快照的网卡清单
+func (n *NetWork) Encode(w io.Writer, enc Encoder) error {
+	return enc.Encode(w, n.Current().Inventory())
+}
+
+// encodeString 编码为字符串, 用于兼容旧的字符串接口
+func encodeString(enc Encoder, inv []IfiStat) string {
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, inv); err != nil {
+		return ""
+	}
+	return buf.String()
+}
@@ -0,0 +1,71 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/procfs"
+)
+
+func collectFixture(t *testing.T) *NetWork {
+	n, now := newFixtureNetwork(t, "testdata/host/t0")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(10 * time.Second)
+	n.fs = procfs.NewFS("testdata/host/t1")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestLegacyEncoders(t *testing.T) {
+	n := collectFixture(t)
+
+	want := "203.0.113.10=eth0=(100000|200000)$10.0.0.5=eth1=(10000|5000)$"
+	if got := n.EthByteSetFunc(""); got != want {
+		t.Fatalf("EthByteSetFunc = %q, want %q", got, want)
+	}
+	//多次调用不会累加
+	if got := n.EthByteSetFunc(""); got != want {
+		t.Fatalf("EthByteSetFunc grew to %q", got)
+	}
+
+	if got := n.EthModelFunc(""); got != "eth0|203.0.113.10|1000$eth1|10.0.0.5|0$" {
+		t.Fatalf("EthModelFunc = %q", got)
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	n := collectFixture(t)
+
+	var buf bytes.Buffer
+	if err := n.Encode(&buf, JSONEncoder{}); err != nil {
+		t.Fatal(err)
+	}
+	var inv []IfiStat
+	if err := json.Unmarshal(buf.Bytes(), &inv); err != nil {
+		t.Fatal(err)
+	}
+	if len(inv) != 2 || inv[0].Name != "eth0" || inv[0].Index != 2 || inv[0].SendByteAvg != 200000 {
+		t.Fatalf("unexpected inventory: %+v", inv)
+	}
+}
+
+func TestTextEncoder(t *testing.T) {
+	n := collectFixture(t)
+
+	var buf bytes.Buffer
+	if err := n.Encode(&buf, TextEncoder{}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "eth0") || !strings.Contains(lines[2], "10.0.0.5") {
+		t.Fatalf("unexpected table:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,87 @@
+package net
+
+const (
+	duplexFull    = "full"
+	duplexHalf    = "half"
+	duplexUnknown = "unknown"
+)
+
+// linkMode 网卡链路模式
+type linkMode struct {
+	Speed   float64 //速率(Mb/s), 0表示未知
+	Duplex  string  //双工模式
+	Autoneg bool    //是否自协商
+	Port    string  //端口类型
+}
+
+// linkState 链路状态, 变化时才重新获取链路模式
+type linkState struct {
+	index          int
+	operstate      string
+	carrierChanges uint64
+}
+
+type linkCacheEntry struct {
+	state  linkState
+	mode   linkMode
+	static linkStatic
+}
+
+// linkMode 获取网卡链路模式, 链路状态不变时使用缓存; attrs有效时不读取sysfs中的链路状态
+func (n *NetWork) linkMode(name string, index int, attrs linkAttrs) linkMode {
+	state := linkState{index: index}
+	if attrs.valid {
+		state.operstate, state.carrierChanges = attrs.OperState, attrs.CarrierChanges
+	} else {
+		//每个周期每个网卡都会读取, 只拼接一次目录
+		dir := n.fs.Sys("class", "net", name)
+		state.operstate, _ = n.fs.ReadString(dir + "/operstate")
+		state.carrierChanges, _ = n.fs.ReadUint(dir + "/carrier_changes")
+	}
+
+	e, exists := n.linkCache[name]
+	if exists && e.state == state {
+		return e.mode
+	}
+
+	if !exists || e.state.index != index {
+		//驱动和网卡类型在ifindex不变时不会变化
+		e.static = n.readLinkStatic(name, index)
+	}
+	e.state = state
+	e.mode = n.readLinkMode(name)
+	n.linkCache[name] = e
+	return e.mode
+}
+
+// readLinkMode 优先从sysfs读取, 读不到的再通过ethtool ioctl获取
+func (n *NetWork) readLinkMode(name string) linkMode {
+	mode := linkMode{Duplex: duplexUnknown}
+
+	//虚拟网卡和链路down时speed为-1或读取失败
+	if speed, err := n.fs.ReadInt(n.fs.Sys("class", "net", name, "speed")); err == nil && speed > 0 {
+		mode.Speed = float64(speed)
+	}
+	if duplex, err := n.fs.ReadString(n.fs.Sys("class", "net", name, "duplex")); err == nil && duplex != "" {
+		mode.Duplex = duplex
+	}
+
+	if !n.fs.IsHost() {
+		//回放现场数据时不能对本机网卡执行ioctl
+		return mode
+	}
+
+	ioctlMode, err := ethtoolLinkMode(name)
+	if err != nil {
+		return mode
+	}
+	if mode.Speed == 0 {
+		mode.Speed = ioctlMode.Speed
+	}
+	if mode.Duplex == duplexUnknown {
+		mode.Duplex = ioctlMode.Duplex
+	}
+	mode.Autoneg = ioctlMode.Autoneg
+	mode.Port = ioctlMode.Port
+	return mode
+}
@@ -0,0 +1,59 @@
+package net
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSysFile(t *testing.T, root, name, file, value string) {
+	path := filepath.Join(root, "sys", "class", "net", name, file)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinkModeFromSysfs(t *testing.T) {
+	n, _ := newFixtureNetwork(t, "testdata/host/t0")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	eth0 := n.Current().IfiMap["eth0"]
+	if eth0.Speed != 1000 || eth0.Duplex != duplexFull {
+		t.Fatalf("eth0 speed=%v duplex=%v", eth0.Speed, eth0.Duplex)
+	}
+	//虚拟网卡speed为-1
+	eth1 := n.Current().IfiMap["eth1"]
+	if eth1.Speed != 0 || eth1.Duplex != duplexUnknown {
+		t.Fatalf("eth1 speed=%v duplex=%v", eth1.Speed, eth1.Duplex)
+	}
+}
+
+func TestLinkModeCache(t *testing.T) {
+	root := t.TempDir()
+	writeSysFile(t, root, "eth0", "speed", "1000")
+	writeSysFile(t, root, "eth0", "operstate", "up")
+	writeSysFile(t, root, "eth0", "carrier_changes", "2")
+
+	n, _ := newFixtureNetwork(t, root)
+	if mode := n.linkMode("eth0", 2, linkAttrs{}); mode.Speed != 1000 {
+		t.Fatalf("speed = %v, want 1000", mode.Speed)
+	}
+
+	//链路状态未变化时不重新读取
+	writeSysFile(t, root, "eth0", "speed", "10000")
+	if mode := n.linkMode("eth0", 2, linkAttrs{}); mode.Speed != 1000 {
+		t.Fatalf("speed = %v, want cached 1000", mode.Speed)
+	}
+
+	//链路重新协商后刷新
+	writeSysFile(t, root, "eth0", "carrier_changes", "4")
+	if mode := n.linkMode("eth0", 2, linkAttrs{}); mode.Speed != 10000 {
+		t.Fatalf("speed = %v, want 10000", mode.Speed)
+	}
+}
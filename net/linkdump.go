@@ -0,0 +1,235 @@
+package net
+
+import (
+	"net"
+)
+
+// RTM_GETLINK/RTM_GETADDR消息的属性, 与linux内核定义相同
+const (
+	iflaAddress        = 1
+	iflaMTU            = 4
+	iflaOperstate      = 16
+	iflaStats64        = 23
+	iflaCarrierChanges = 35
+
+	ifaAddress = 1
+	ifaLocal   = 2
+
+	afInet  = 2
+	afInet6 = 10
+
+	rtnlLinkStats64Len = 23 * 8 //struct rtnl_link_stats64中到tx_compressed为止的字段
+)
+
+// operStates IFLA_OPERSTATE的取值, 与/sys/class/net/<name>/operstate相同
+var operStates = []string{"unknown", "notpresent", "down", "lowerlayerdown", "testing", "dormant", "up"}
+
+// linkAttrs netlink一并返回的链路属性, 有效时不再读取sysfs
+type linkAttrs struct {
+	valid          bool
+	MTU            int
+	MAC            string
+	OperState      string
+	CarrierChanges uint64
+}
+
+// parseIfinfo 解析RTM_NEWLINK消息, 计数的换算与内核输出/proc/net/dev时相同
+func parseIfinfo(data []byte) (devStat, *Link, bool) {
+	if len(data) < ifinfomsgLen {
+		return devStat{}, nil, false
+	}
+	link := &Link{Index: int(int32(nativeEndian.Uint32(data[4:8])))}
+	attrs := parseAttrs(data[ifinfomsgLen:])
+
+	link.Name = cString(attrs[iflaIfname])
+	if link.Name == "" {
+		return devStat{}, nil, false
+	}
+	link.attrs.valid = true
+	if b := attrs[iflaMTU]; len(b) >= 4 {
+		link.attrs.MTU = int(nativeEndian.Uint32(b))
+	}
+	if b := attrs[iflaAddress]; len(b) > 0 {
+		link.attrs.MAC = net.HardwareAddr(b).String()
+	}
+	link.attrs.OperState = operStates[0]
+	if b := attrs[iflaOperstate]; len(b) >= 1 && int(b[0]) < len(operStates) {
+		link.attrs.OperState = operStates[b[0]]
+	}
+	if b := attrs[iflaCarrierChanges]; len(b) >= 4 {
+		link.attrs.CarrierChanges = uint64(nativeEndian.Uint32(b))
+	}
+
+	stat := devStat{Name: link.Name, width: counter64}
+	b := attrs[iflaStats64]
+	if len(b) < rtnlLinkStats64Len {
+		return stat, link, true
+	}
+	//struct rtnl_link_stats64
+	var s [23]uint64
+	for i := range s {
+		s[i] = nativeEndian.Uint64(b[i*8:])
+	}
+	const (
+		rxPackets = iota
+		txPackets
+		rxBytes
+		txBytes
+		rxErrors
+		txErrors
+		rxDropped
+		txDropped
+		multicast
+		collisions
+		rxLengthErrors
+		rxOverErrors
+		rxCrcErrors
+		rxFrameErrors
+		rxFifoErrors
+		rxMissedErrors
+		txAbortedErrors
+		txCarrierErrors
+		txFifoErrors
+		txHeartbeatErrors
+		txWindowErrors
+		rxCompressed
+		txCompressed
+	)
+	stat.RecvByte = s[rxBytes]
+	stat.RecvPkg = s[rxPackets]
+	stat.RecvErr = s[rxErrors]
+	stat.RecvDrop = s[rxDropped] + s[rxMissedErrors]
+	stat.RecvFifo = s[rxFifoErrors]
+	stat.RecvFrame = s[rxLengthErrors] + s[rxOverErrors] + s[rxCrcErrors] + s[rxFrameErrors]
+	stat.RecvCompressed = s[rxCompressed]
+	stat.RecvMulticast = s[multicast]
+
+	stat.SendByte = s[txBytes]
+	stat.SendPkg = s[txPackets]
+	stat.SendErr = s[txErrors]
+	stat.SendDrop = s[txDropped]
+	stat.SendFifo = s[txFifoErrors]
+	stat.SendColls = s[collisions]
+	stat.SendCarrier = s[txCarrierErrors] + s[txAbortedErrors] + s[txWindowErrors] + s[txHeartbeatErrors]
+	stat.SendCompressed = s[txCompressed]
+	return stat, link, true
+}
+
+// parseIfaddr 解析RTM_NEWADDR消息; IPv4点对点地址的IFA_ADDRESS是对端地址, 优先使用IFA_LOCAL
+func parseIfaddr(data []byte) (int, net.Addr, bool) {
+	if len(data) < ifaddrmsgLen {
+		return 0, nil, false
+	}
+	family, prefixLen := data[0], int(data[1])
+	index := int(nativeEndian.Uint32(data[4:8]))
+	attrs := parseAttrs(data[ifaddrmsgLen:])
+
+	ip := attrs[ifaLocal]
+	if ip == nil {
+		ip = attrs[ifaAddress]
+	}
+	bits := 0
+	switch {
+	case family == afInet && len(ip) == net.IPv4len:
+		bits = 32
+	case family == afInet6 && len(ip) == net.IPv6len:
+		bits = 128
+	default:
+		return 0, nil, false
+	}
+	return index, &net.IPNet{IP: net.IP(append([]byte{}, ip...)), Mask: net.CIDRMask(prefixLen, bits)}, true
+}
+
+// linkDumper 一次dump本机所有网卡及其地址
+type linkDumper interface {
+	dumpLinks(fn func(data []byte)) error
+	dumpAddrs(fn func(data []byte)) error
+}
+
+// readLinkDump 由RTM_GETLINK和RTM_GETADDR的结果生成网卡计数和网络接口表, 代替/proc/net/dev和SystemResolver
+func readLinkDump(d linkDumper) ([]devStat, StaticResolver, error) {
+	var stats []devStat
+	r := StaticResolver{}
+	byIndex := make(map[int]*Link)
+	err := d.dumpLinks(func(data []byte) {
+		stat, link, ok := parseIfinfo(data)
+		if !ok {
+			return
+		}
+		stats = append(stats, stat)
+		r[link.Name] = link
+		byIndex[link.Index] = link
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = d.dumpAddrs(func(data []byte) {
+		index, addr, ok := parseIfaddr(data)
+		if !ok {
+			return
+		}
+		if link, exists := byIndex[index]; exists {
+			link.Addrs = append(link.Addrs, addr)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return stats, r, nil
+}
+
+// readHost 读取本机命名空间的网卡计数和网络接口, 开启WithNetlinkStats且读取本机时使用netlink;
+// resolver支持一次列出所有网络接口时每次采集只查询一次
+func (n *NetWork) readHost() ([]devStat, Resolver, error) {
+	if n.netlinkStats && n.fs.IsHost() {
+		stats, links, err := readNetlinkStats()
+		if err != nil {
+			return nil, nil, err
+		}
+		return stats, n.netlinkResolver(links), nil
+	}
+	stats, err := n.readDev(n.fs.Proc("net", "dev"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return stats, n.hostResolver(), nil
+}
+
+// hostResolver 本次采集使用的本机命名空间resolver
+func (n *NetWork) hostResolver() Resolver {
+	if l, ok := n.resolver.(linkLister); ok {
+		if links, err := l.Links(); err == nil {
+			return links
+		}
+	}
+	return n.resolver
+}
+
+// netlinkResolver 没有通过WithResolver指定resolver时直接使用netlink dump的结果,
+// 否则以指定的resolver为准, netlink只补充ifindex相同的网卡的链路属性
+func (n *NetWork) netlinkResolver(links StaticResolver) Resolver {
+	if _, ok := n.resolver.(SystemResolver); ok {
+		return links
+	}
+	return netlinkOverlay{resolver: n.hostResolver(), links: links}
+}
+
+// netlinkOverlay 在指定的resolver之上叠加netlink读取的链路属性
+type netlinkOverlay struct {
+	resolver Resolver
+	links    StaticResolver
+}
+
+func (o netlinkOverlay) LinkByName(name string) (*Link, error) {
+	link, err := o.resolver.LinkByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if nl, exists := o.links[name]; exists && nl.Index == link.Index {
+		cp := *link
+		cp.attrs = nl.attrs
+		return &cp, nil
+	}
+	return link, nil
+}
@@ -0,0 +1,37 @@
+package net
+
+import (
+	"syscall"
+)
+
+// netlinkLinkDumper 在同一个netlink连接上dump网卡和地址
+type netlinkLinkDumper struct {
+	c *netlinkConn
+}
+
+func (d netlinkLinkDumper) dump(typ uint16, body []byte, fn func(data []byte)) error {
+	return d.c.dump(typ, body, func(_ uint16, data []byte) { fn(data) })
+}
+
+func (d netlinkLinkDumper) dumpLinks(fn func(data []byte)) error {
+	return d.dump(syscall.RTM_GETLINK, make([]byte, ifinfomsgLen), fn)
+}
+
+func (d netlinkLinkDumper) dumpAddrs(fn func(data []byte)) error {
+	return d.dump(syscall.RTM_GETADDR, make([]byte, ifaddrmsgLen), fn)
+}
+
+// readNetlinkStats 通过netlink读取本机所有网卡的IFLA_STATS64、链路属性和地址
+func readNetlinkStats() ([]devStat, StaticResolver, error) {
+	c, err := dialNetlink()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.close()
+	return readLinkDump(netlinkLinkDumper{c: c})
+}
+
+func systemLinks() (StaticResolver, error) {
+	_, r, err := readNetlinkStats()
+	return r, err
+}
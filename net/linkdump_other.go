@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+package net
+
+import (
+	"net"
+)
+
+func readNetlinkStats() ([]devStat, StaticResolver, error) {
+	return nil, nil, errNetlinkUnsupported
+}
+
+func systemLinks() (StaticResolver, error) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	r := make(StaticResolver, len(ifis))
+	for _, ifi := range ifis {
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		r[ifi.Name] = &Link{Index: ifi.Index, Name: ifi.Name, Addrs: addrs}
+	}
+	return r, nil
+}
@@ -0,0 +1,253 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	nativeEndian.PutUint32(b, v)
+	return b
+}
+
+// ifinfoStatsMsg 构造带IFLA_STATS64的RTM_NEWLINK消息, stats按struct rtnl_link_stats64的字段顺序
+func ifinfoStatsMsg(index int, name string, stats [23]uint64) []byte {
+	b := make([]byte, ifinfomsgLen)
+	nativeEndian.PutUint32(b[4:8], uint32(index))
+	var s []byte
+	for _, v := range stats {
+		s = append(s, u64(v)...)
+	}
+	//rx_nohandler等新字段
+	s = append(s, u64(0)...)
+	return append(b, attrs(
+		attr(iflaIfname, append([]byte(name), 0)),
+		attr(iflaMTU, u32(1500)),
+		attr(iflaAddress, []byte{0x52, 0x54, 0x00, 0x12, 0x34, 0x56}),
+		attr(iflaOperstate, []byte{6}),
+		attr(iflaCarrierChanges, u32(4)),
+		attr(iflaStats64, s),
+	)...)
+}
+
+func ifaddrMsg(family byte, prefixLen int, index int, list ...[]byte) []byte {
+	b := make([]byte, ifaddrmsgLen)
+	b[0], b[1] = family, byte(prefixLen)
+	nativeEndian.PutUint32(b[4:8], uint32(index))
+	return append(b, attrs(list...)...)
+}
+
+type fakeLinkDumper struct {
+	links, addrs [][]byte
+}
+
+func (d fakeLinkDumper) dumpLinks(fn func(data []byte)) error {
+	for _, m := range d.links {
+		fn(m)
+	}
+	return nil
+}
+
+func (d fakeLinkDumper) dumpAddrs(fn func(data []byte)) error {
+	for _, m := range d.addrs {
+		fn(m)
+	}
+	return nil
+}
+
+func TestReadLinkDump(t *testing.T) {
+	var stats [23]uint64
+	for i := range stats {
+		stats[i] = uint64(i + 1)
+	}
+	d := fakeLinkDumper{
+		links: [][]byte{ifinfoStatsMsg(2, "eth0", stats)},
+		addrs: [][]byte{
+			ifaddrMsg(afInet, 24, 2, attr(ifaAddress, net.ParseIP("203.0.113.10").To4())),
+			//点对点地址, IFA_ADDRESS为对端
+			ifaddrMsg(afInet, 32, 2, attr(ifaAddress, net.ParseIP("198.51.100.2").To4()), attr(ifaLocal, net.ParseIP("198.51.100.1").To4())),
+			ifaddrMsg(afInet6, 64, 2, attr(ifaAddress, net.ParseIP("2001:db8::10"))),
+			ifaddrMsg(afInet, 24, 9, attr(ifaAddress, net.ParseIP("10.0.0.1").To4())),
+		},
+	}
+	devStats, r, err := readLinkDump(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//与内核输出/proc/net/dev时的换算相同
+	want := devStat{
+		Name:     "eth0",
+		RecvByte: 3, RecvPkg: 1, RecvErr: 5, RecvDrop: 7 + 16,
+		RecvFifo: 15, RecvFrame: 11 + 12 + 13 + 14, RecvCompressed: 22, RecvMulticast: 9,
+		SendByte: 4, SendPkg: 2, SendErr: 6, SendDrop: 8,
+		SendFifo: 19, SendColls: 10, SendCarrier: 18 + 17 + 21 + 20, SendCompressed: 23,
+		width: counter64,
+	}
+	if len(devStats) != 1 || devStats[0] != want {
+		t.Fatalf("got %+v, want %+v", devStats, want)
+	}
+
+	link, err := r.LinkByName("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var addrs []string
+	for _, a := range link.Addrs {
+		addrs = append(addrs, a.String())
+	}
+	if got := strings.Join(addrs, " "); got != "203.0.113.10/24 198.51.100.1/32 2001:db8::10/64" {
+		t.Fatalf("got addrs %v", got)
+	}
+	wantAttrs := linkAttrs{valid: true, MTU: 1500, MAC: "52:54:00:12:34:56", OperState: "up", CarrierChanges: 4}
+	if link.Index != 2 || link.attrs != wantAttrs {
+		t.Fatalf("got link %+v", link)
+	}
+}
+
+func TestNetlinkResolverWithResolver(t *testing.T) {
+	attrs := linkAttrs{valid: true, MTU: 9000, OperState: "up"}
+	links := StaticResolver{
+		"eth0": {Index: 2, Name: "eth0", Addrs: []net.Addr{&net.IPNet{IP: net.ParseIP("203.0.113.10").To4(), Mask: net.CIDRMask(24, 32)}}, attrs: attrs},
+		"eth1": {Index: 3, Name: "eth1", attrs: attrs},
+	}
+
+	n := NewNetwork(nil, nil, nil, nil, WithNetlinkStats())
+	if r := n.netlinkResolver(links); !reflect.DeepEqual(r, links) {
+		t.Fatalf("default resolver should use the netlink dump, got %T", r)
+	}
+
+	configured, err := ParseIPAddr(strings.NewReader("2: eth0    inet 198.51.100.1/24 scope global eth0\n" +
+		"4: eth1    inet 10.0.0.1/24 scope global eth1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	WithResolver(configured)(n)
+	r := n.netlinkResolver(links)
+
+	link, err := r.LinkByName("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(link.Addrs) != 1 || link.Addrs[0].String() != "198.51.100.1/24" || link.attrs != attrs {
+		t.Fatalf("configured resolver not applied: %+v", link)
+	}
+	//ifindex不同时不是同一个网卡, 不使用netlink的链路属性
+	if link, err := r.LinkByName("eth1"); err != nil || link.Index != 4 || link.attrs.valid {
+		t.Fatalf("got %+v, %v", link, err)
+	}
+	if _, err := r.LinkByName("eth2"); err != ErrLinkNotFound {
+		t.Fatalf("unknown link: %v", err)
+	}
+}
+
+func TestParseIfinfoWithoutStats(t *testing.T) {
+	b := make([]byte, ifinfomsgLen)
+	nativeEndian.PutUint32(b[4:8], 3)
+	b = append(b, attr(iflaIfname, []byte("eth1\x00"))...)
+	stat, link, ok := parseIfinfo(b)
+	if !ok || stat != (devStat{Name: "eth1", width: counter64}) || link.attrs.OperState != "unknown" {
+		t.Fatalf("got %+v %+v %v", stat, link, ok)
+	}
+	if _, _, ok := parseIfinfo(make([]byte, ifinfomsgLen)); ok {
+		t.Fatal("link without name should be skipped")
+	}
+}
+
+func TestNetlinkStatsMatchesProcfs(t *testing.T) {
+	stats, r, err := readNetlinkStats()
+	if err != nil {
+		t.Skip(err)
+	}
+	n := NewNetwork(nil, nil, nil, nil)
+	dev, err := n.readDev(n.fs.Proc("net", "dev"))
+	if err != nil {
+		t.Skip(err)
+	}
+	if len(stats) != len(dev) {
+		t.Fatalf("netlink %d links, /proc/net/dev %d", len(stats), len(dev))
+	}
+	for _, s := range dev {
+		if _, err := r.LinkByName(s.Name); err != nil {
+			t.Errorf("%s missing from netlink dump", s.Name)
+		}
+	}
+}
+
+// benchLinks 生成count个网卡的/proc/net/dev和netlink消息
+func benchLinks(count int) (string, fakeLinkDumper) {
+	var dev strings.Builder
+	dev.WriteString("Inter-|   Receive                                                |  Transmit\n")
+	dev.WriteString(" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n")
+	var d fakeLinkDumper
+	var stats [23]uint64
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("veth%d", i)
+		fmt.Fprintf(&dev, "%s: 123456789 12345 0 0 0 0 0 0 987654321 54321 0 0 0 0 0 0\n", name)
+		d.links = append(d.links, ifinfoStatsMsg(i+2, name, stats))
+		d.addrs = append(d.addrs, ifaddrMsg(afInet, 24, i+2, attr(ifaAddress, net.IPv4(10, byte(i>>8), byte(i), 1).To4())))
+	}
+	return dev.String(), d
+}
+
+func BenchmarkParseDev(b *testing.B) {
+	dev, _ := benchLinks(100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseDev(strings.NewReader(dev)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadLinkDump(b *testing.B) {
+	_, d := benchLinks(100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := readLinkDump(d); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkCollect(b *testing.B, opts ...Option) {
+	n := NewNetwork(nil, []string{"lo"}, nil, nil, opts...)
+	if err := n.Collect(context.Background()); err != nil {
+		b.Skip(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := n.Collect(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// perLinkResolver 只能逐个网卡查询地址, 即没有linkLister时的路径
+type perLinkResolver struct{}
+
+func (perLinkResolver) LinkByName(name string) (*Link, error) {
+	return SystemResolver{}.LinkByName(name)
+}
+
+// BenchmarkCollectProcfsPerLink 本机/proc/net/dev加逐个网卡查询地址
+func BenchmarkCollectProcfsPerLink(b *testing.B) {
+	benchmarkCollect(b, WithResolver(perLinkResolver{}))
+}
+
+// BenchmarkCollectProcfs 本机/proc/net/dev加一次netlink dump查询地址(SystemResolver.Links),
+// 与BenchmarkCollectNetlink的差别只是计数来自/proc/net/dev的解析
+func BenchmarkCollectProcfs(b *testing.B) {
+	benchmarkCollect(b)
+}
+
+// BenchmarkCollectNetlink 本机一次netlink dump同时得到计数和地址
+func BenchmarkCollectNetlink(b *testing.B) {
+	benchmarkCollect(b, WithNetlinkStats())
+}
@@ -0,0 +1,149 @@
+package net
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	LinkTypePhysical = "physical"
+	LinkTypeVeth     = "veth"
+	LinkTypeBridge   = "bridge"
+	LinkTypeBond     = "bond"
+	LinkTypeTeam     = "team"
+	LinkTypeVlan     = "vlan"
+	LinkTypeTun      = "tun"
+	LinkTypeLoopback = "loopback"
+	LinkTypeVirtual  = "virtual" //其他虚拟网卡
+
+	arphrdLoopback = 772 //ARPHRD_LOOPBACK
+)
+
+// driverInfo 网卡驱动信息
+type driverInfo struct {
+	Driver  string //驱动名
+	BusInfo string //总线地址, 如PCI地址0000:00:03.0
+}
+
+// linkStatic ifindex不变时不会变化的网卡信息
+type linkStatic struct {
+	driverInfo
+	Type string //网卡类型
+}
+
+// linkMeta 网卡元数据
+type linkMeta struct {
+	linkStatic
+	MTU            int    //MTU
+	MAC            string //硬件地址
+	OperState      string //运行状态 up/down/unknown等
+	CarrierChanges uint64 //链路up/down累计次数
+}
+
+// linkMeta 获取网卡元数据, 须在linkMode之后调用以使用同一次读取的链路状态
+func (n *NetWork) linkMeta(name string, attrs linkAttrs) linkMeta {
+	e := n.linkCache[name]
+	meta := linkMeta{
+		linkStatic:     e.static,
+		OperState:      e.state.operstate,
+		CarrierChanges: e.state.carrierChanges,
+	}
+	if attrs.valid {
+		meta.MTU, meta.MAC = attrs.MTU, attrs.MAC
+		return meta
+	}
+	dir := n.fs.Sys("class", "net", name)
+	if mtu, err := n.fs.ReadInt(dir + "/mtu"); err == nil {
+		meta.MTU = int(mtu)
+	}
+	meta.MAC, _ = n.fs.ReadString(dir + "/address")
+	return meta
+}
+
+// readLinkStatic 读取网卡驱动和类型
+func (n *NetWork) readLinkStatic(name string, index int) linkStatic {
+	static := linkStatic{driverInfo: n.readDriverInfo(name)}
+	static.Type = n.readLinkType(name, index, static.Driver)
+	return static
+}
+
+// readDriverInfo 优先从sysfs的device链接读取, 没有device的虚拟网卡再通过ethtool ioctl获取
+func (n *NetWork) readDriverInfo(name string) driverInfo {
+	var info driverInfo
+	if target, err := os.Readlink(n.fs.Sys("class", "net", name, "device")); err == nil {
+		info.BusInfo = filepath.Base(target)
+	}
+	if target, err := os.Readlink(n.fs.Sys("class", "net", name, "device", "driver")); err == nil {
+		info.Driver = filepath.Base(target)
+	}
+
+	if info.Driver != "" || !n.fs.IsHost() {
+		return info
+	}
+
+	ioctlInfo, err := ethtoolDriverInfo(name)
+	if err != nil {
+		return info
+	}
+	info.Driver = ioctlInfo.Driver
+	if info.BusInfo == "" {
+		info.BusInfo = ioctlInfo.BusInfo
+	}
+	return info
+}
+
+// readLinkType 根据sysfs判断网卡类型
+func (n *NetWork) readLinkType(name string, index int, driver string) string {
+	dir := n.fs.Sys("class", "net", name)
+	exists := func(file string) bool {
+		_, err := os.Stat(filepath.Join(dir, file))
+		return err == nil
+	}
+
+	if typ, err := n.fs.ReadInt(filepath.Join(dir, "type")); err == nil && typ == arphrdLoopback {
+		return LinkTypeLoopback
+	}
+	switch {
+	case exists("bridge"):
+		return LinkTypeBridge
+	case exists("bonding"):
+		return LinkTypeBond
+	case exists("tun_flags"):
+		return LinkTypeTun
+	}
+
+	switch devType := n.readDevType(name); devType {
+	case "vlan", "bridge", "bond":
+		return devType
+	}
+
+	switch driver {
+	case "veth":
+		return LinkTypeVeth
+	case "team":
+		return LinkTypeTeam
+	}
+	if exists("device") {
+		return LinkTypePhysical
+	}
+	//没有device且iflink指向其他网卡, 通常是veth
+	if iflink, err := n.fs.ReadInt(filepath.Join(dir, "iflink")); err == nil && index > 0 && int(iflink) != index {
+		return LinkTypeVeth
+	}
+	return LinkTypeVirtual
+}
+
+// readDevType 读取uevent中的DEVTYPE
+func (n *NetWork) readDevType(name string) string {
+	uevent, err := n.fs.ReadString(n.fs.Sys("class", "net", name, "uevent"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(uevent, "\n") {
+		if v := strings.TrimPrefix(line, "DEVTYPE="); v != line {
+			return v
+		}
+	}
+	return ""
+}
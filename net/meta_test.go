@@ -0,0 +1,88 @@
+package net
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinkMetaCollect(t *testing.T) {
+	n := collectFixture(t)
+
+	eth0 := n.Current().IfiMap["eth0"]
+	if eth0.MTU != 1500 || eth0.MAC != "52:54:00:12:34:56" || eth0.OperState != "up" {
+		t.Fatalf("eth0 mtu=%v mac=%v operstate=%v", eth0.MTU, eth0.MAC, eth0.OperState)
+	}
+	//carrier_changes 2 -> 4
+	if eth0.CarrierChanges != 4 || eth0.CarrierFlaps != 2 {
+		t.Fatalf("eth0 carrier_changes=%v flaps=%v", eth0.CarrierChanges, eth0.CarrierFlaps)
+	}
+
+	eth1 := n.Current().IfiMap["eth1"]
+	if eth1.MTU != 9000 || eth1.CarrierFlaps != 0 {
+		t.Fatalf("eth1 mtu=%v flaps=%v", eth1.MTU, eth1.CarrierFlaps)
+	}
+}
+
+func TestReadLinkType(t *testing.T) {
+	root := t.TempDir()
+	sys := filepath.Join(root, "sys", "class", "net")
+
+	writeSysFile(t, root, "lo", "type", "772")
+
+	pci := filepath.Join(root, "sys", "devices", "pci0000:00", "0000:00:03.0")
+	if err := os.MkdirAll(pci, 0755); err != nil {
+		t.Fatal(err)
+	}
+	drv := filepath.Join(root, "sys", "bus", "pci", "drivers", "virtio_net")
+	if err := os.MkdirAll(drv, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(drv, filepath.Join(pci, "driver")); err != nil {
+		t.Fatal(err)
+	}
+	writeSysFile(t, root, "eth0", "type", "1")
+	if err := os.Symlink(pci, filepath.Join(sys, "eth0", "device")); err != nil {
+		t.Fatal(err)
+	}
+
+	writeSysFile(t, root, "br0", "type", "1")
+	if err := os.MkdirAll(filepath.Join(sys, "br0", "bridge"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(sys, "bond0", "bonding"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeSysFile(t, root, "tun0", "tun_flags", "0x1001")
+	writeSysFile(t, root, "eth0.100", "uevent", "DEVTYPE=vlan\nINTERFACE=eth0.100\nIFINDEX=7")
+	writeSysFile(t, root, "eth0.100", "iflink", "2")
+	writeSysFile(t, root, "veth1a2b", "iflink", "9")
+	writeSysFile(t, root, "dummy0", "iflink", "10")
+
+	n, _ := newFixtureNetwork(t, root)
+	cases := []struct {
+		name  string
+		index int
+		want  string
+	}{
+		{"lo", 1, LinkTypeLoopback},
+		{"eth0", 2, LinkTypePhysical},
+		{"br0", 3, LinkTypeBridge},
+		{"bond0", 4, LinkTypeBond},
+		{"tun0", 5, LinkTypeTun},
+		{"eth0.100", 7, LinkTypeVlan},
+		{"veth1a2b", 8, LinkTypeVeth},
+		{"dummy0", 10, LinkTypeVirtual},
+	}
+	for _, c := range cases {
+		static := n.readLinkStatic(c.name, c.index)
+		if static.Type != c.want {
+			t.Errorf("%s type = %v, want %v", c.name, static.Type, c.want)
+		}
+	}
+
+	info := n.readDriverInfo("eth0")
+	if info.Driver != "virtio_net" || info.BusInfo != "0000:00:03.0" {
+		t.Fatalf("eth0 driver info = %+v", info)
+	}
+}
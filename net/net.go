@@ -1,32 +1,69 @@
 package net
 
 import (
-	"bufio"
-	"errors"
-	"fmt"
+	"context"
+	"github.com/enoch300/collectd/procfs"
 	"github.com/enoch300/collectd/utils"
-	"io"
 	"net"
-	"os"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Ifi struct {
 	Name  string  //网卡接口
-	Ip    string  //网卡IP
-	Speed float64 //网卡速率
-
-	RecvByte uint64 //接收字节数
-	RecvPkg  uint64 //接收包数
-	RecvErr  uint64 //接收错误包数
-	RecvDrop uint64 //接收丢包包数
-
-	SendByte uint64 //发送字节数
-	SendPkg  uint64 //发送包数
-	SendErr  uint64 //发送错误包数
-	SendDrop uint64 //发送丢包包数
+	Index int     //内核ifindex, 只在同一命名空间内唯一
+	Ip    string  //网卡主IP, 优先IPv4
+	Addrs []Addr  //网卡所有地址, 不含链路本地地址, 发布后不可修改
+	Speed float64 //网卡速率(Mb/s), 0表示未知
+
+	Netns      string //网络命名空间, agent所在的命名空间为空, 其他为ip netns名称或net:[inode]
+	NetnsInode uint64 //网络命名空间inode
+
+	ContainerID      string //所属容器ID, 本机一侧的veth为对端所在的容器
+	ContainerName    string //所属容器名, 查询不到时为空
+	ContainerRuntime string //容器运行时
+
+	RecvUseRate float64 //接收带宽使用率(%), 速率未知时为0
+	SendUseRate float64 //发送带宽使用率(%), 速率未知时为0
+
+	Duplex  string //双工模式 full/half/unknown
+	Autoneg bool   //是否自协商
+	Port    string //端口类型 TP/FIBRE/DA等
+
+	MTU            int    //MTU
+	MAC            string //硬件地址
+	OperState      string //运行状态 up/down/unknown等
+	CarrierChanges uint64 //链路up/down累计次数
+	CarrierFlaps   uint64 //一个周期内链路up/down次数
+	Driver         string //驱动名
+	BusInfo        string //总线地址
+	Type           string //网卡类型 physical/veth/bridge/bond/team/vlan/tun等
+
+	Master   string    //所属的bond/team/bridge
+	Lower    string    //VLAN的父网卡
+	Carriers []string  //承载本网卡流量的网卡, 由近及远, 发布后不可修改
+	Bond     *BondInfo //bond状态, 其他网卡为nil, 发布后不可修改
+	Counted  bool      //是否计入整机统计, 承载网卡也被采集时为false
+
+	RecvByte       uint64 //接收字节数
+	RecvPkg        uint64 //接收包数
+	RecvErr        uint64 //接收错误包数
+	RecvDrop       uint64 //接收丢包包数
+	RecvFifo       uint64 //接收FIFO溢出数
+	RecvFrame      uint64 //接收帧错误数
+	RecvCompressed uint64 //接收压缩包数
+	RecvMulticast  uint64 //接收组播包数
+
+	SendByte       uint64 //发送字节数
+	SendPkg        uint64 //发送包数
+	SendErr        uint64 //发送错误包数
+	SendDrop       uint64 //发送丢包包数
+	SendFifo       uint64 //发送FIFO溢出数
+	SendColls      uint64 //发送冲突数
+	SendCarrier    uint64 //发送载波错误数
+	SendCompressed uint64 //发送压缩包数
 
 	RecvByteAvg    float64 //一个周期平均接收字节数
 	RecvPkgAvg     float64 //一个周期平均接收包数
@@ -35,6 +72,11 @@ type Ifi struct {
 	RecvErrRate    float64 //一个周期收包错误率
 	RecvDropRate   float64 //一个周期收包丢包率
 
+	RecvFifoAvg       float64 //一个周期平均每秒接收FIFO溢出数
+	RecvFrameAvg      float64 //一个周期平均每秒接收帧错误数
+	RecvCompressedAvg float64 //一个周期平均每秒接收压缩包数
+	RecvMulticastAvg  float64 //一个周期平均每秒接收组播包数
+
 	SendByteAvg    float64 //一个周期平均发送字节数
 	SendPkgAvg     float64 //一个周期平均发送包数
 	SendErrPkgAvg  float64 //一个周期平均发包错误数
@@ -42,95 +84,193 @@ type Ifi struct {
 	SendErrRate    float64 //一个周期发包错误率
 	SendDropRate   float64 //一个周期发包丢包率
 
-	BandwidthLimit int   //0不限制, 1被限制
-	Last           int64 //上次采集时间
+	SendFifoAvg       float64 //一个周期平均每秒发送FIFO溢出数
+	SendCollsAvg      float64 //一个周期平均每秒发送冲突数
+	SendCarrierAvg    float64 //一个周期平均每秒发送载波错误数
+	SendCompressedAvg float64 //一个周期平均每秒发送压缩包数
+
+	Resets    uint64    //计数器重置次数
+	LastReset time.Time //上次计数器重置时间
+
+	BandwidthLimit  int     //0不限制, 1被限制
+	RecvLimit       float64 //接收方向限速(Mb/s), 0表示未检测到
+	SendLimit       float64 //发送方向限速(Mb/s), 0表示未检测到
+	RecvLimitSource string  //接收方向限速来源 tbf/htb/cake/police/plateau
+	SendLimitSource string  //发送方向限速来源
+
+	Queues             []QueueStat //收发队列, 只在WithQueues时采集, 每次采集重新分配
+	RecvQueueImbalance float64     //接收队列不均衡度, 见queueImbalance
+	SendQueueImbalance float64     //发送队列不均衡度
+
+	DriverStats    []DriverStat    //驱动统计(ethtool -S), 只在WithDriverCounters时采集, 按include过滤
+	DriverCounters []DriverCounter //跨驱动归一化的计数, 如rx_missed_errors, 用于区分RecvDrop的原因
+
+	Last       time.Time //上次采集时间(含单调时钟)
+	Generation uint64    //最后一次出现在/proc/net/dev中的采集代数
 }
 
 type NetWork struct {
-	IfiMap    map[string]*Ifi
-	IfiNames  []string
 	IgnoreIP  []string //忽略或不监控的IP
 	IgnoreEth []string
 	InIP      []string //内网IP
 	InEth     []string //内网网卡
 	IPV6      bool
 
-	//内网
-	InRecvByteAvg float64 //所有内网网络接口平均接收字节数
-	InSendByteAvg float64 //所有内网网络接口平均发送字节数
+	mu         sync.Mutex      //串行化Collect
+	ifis       map[string]*Ifi //采集工作状态, 以Ifi.Key()为key, 只在Collect内使用
+	generation uint64          //采集代数, 每次Collect加一
+	snapshot   atomic.Value    //最近一次发布的*Snapshot
+	pubMu      sync.Mutex      //串行化快照的发布, Watch只持有pubMu, 不必等待整个Collect
+	pending    []linkMessage   //Watch已应用到快照、尚未应用到工作状态的消息, 由pubMu保护
+	linkCache  map[string]linkCacheEntry
+	plateaus   map[string]*ifiPlateau
+	plateau    plateauConfig
+
+	speedOverrides []speedOverride
+	netlinkStats   bool //本机命名空间通过netlink读取计数和地址
+
+	netns         bool                             //是否采集其他网络命名空间
+	netnsResolver func(ns Netns) (Resolver, error) //查询命名空间内的网络接口
+	netnsPids     map[int]uint64                   //上次枚举时pid到命名空间inode
+	netnsPidsAt   time.Time                        //上次完整读取所有/proc/<pid>/ns/net的时间
+	netnsLinks    map[uint64]*netnsLinks           //命名空间inode到缓存的网络接口
+	events        eventBus
+
+	containers     ContainerResolver          //为nil时不做容器归属
+	containerCache map[uint64]*netnsContainer //命名空间inode到容器
+
+	queues      bool              //是否采集每队列统计和中断
+	driverStats DriverStatsSource //驱动统计, 为nil时不读取
 
-	InRecvPkgAvg float64 //所有内网网络接口平均收包数
-	InSendPkgAvg float64 //所有内网网络接口平均发包数
+	driverCounters bool         //是否采集驱动统计和归一化计数
+	driverInclude  namePatterns //保留在Ifi.DriverStats中的统计项, 为空时保留全部
 
-	InRecvErrPkgAvg float64 //所有内网网络接口平均收包错误数
-	InSendErrPkgAvg float64 //所有内网网络接口平均发包错误数
+	fs       procfs.FS
+	resolver Resolver
+	shaper   ShaperSource
+	now      func() time.Time
+}
 
-	InRecvDropPkgAvg float64 //所有内网网络接口平均收包丢包数
-	InSendDropPkgAvg float64 //所有内网网络接口平均发包丢包数
+// IsInEth 判断网卡任一地址是否属于filterIps中的网段, 网段格式见ParsePrefix
+func (n *Ifi) IsInEth(filterIps []string) bool {
+	return parsePrefixes(filterIps).anyIn(n.Addrs)
+}
+
+// update 用本次采集的计数更新速率, now需包含单调时钟读数以避免墙上时间跳变
+func (n *Ifi) update(stat devStat, now time.Time) {
+	var (
+		recvByteAvg    float64
+		recvPkgAvg     float64
+		recvErrRate    float64
+		recvDropRate   float64
+		recvErrPkgAvg  float64
+		recvDropPkgAvg float64
+
+		sendByteAvg    float64
+		sendPkgAvg     float64
+		sendErrRate    float64
+		sendDropRate   float64
+		sendErrPkgAvg  float64
+		sendDropPkgAvg float64
+
+		recvFifoAvg, recvFrameAvg, recvCompressedAvg, recvMulticastAvg float64
+		sendFifoAvg, sendCollsAvg, sendCarrierAvg, sendCompressedAvg   float64
+	)
+
+	if !n.Last.IsZero() {
+		diffTime := now.Sub(n.Last).Seconds()
+		if diffTime <= 0 {
+			//与上次采集没有时间差(重复采集)，保留上次结果和基准
+			return
+		}
 
-	InRecvDropPkgRate float64 //所有内网网络接口收包丢包率
-	InSendDropPkgRate float64 //所有内网网络接口发包丢包率
+		delta, ok := n.delta(stat)
+		if !ok {
+			//计数器被重置(驱动重载、网卡重置等)，丢弃本周期
+			n.Resets++
+			n.LastReset = now
+		} else {
+			recvByteAvg = float64(delta.RecvByte) / diffTime    //平均每秒接收字节数
+			recvPkgAvg = float64(delta.RecvPkg) / diffTime      //平均每秒接收包数
+			recvErrPkgAvg = float64(delta.RecvErr) / diffTime   //平均每秒接收错误数
+			recvDropPkgAvg = float64(delta.RecvDrop) / diffTime //平均每秒接收丢包数
+			if delta.RecvPkg > 0 {
+				recvErrRate = float64(delta.RecvErr) / float64(delta.RecvPkg)   //一个周期收包错误率
+				recvDropRate = float64(delta.RecvDrop) / float64(delta.RecvPkg) //一个周期收包丢包率
+			}
 
-	InRecvErrPkgRate float64 //所有内网网络接口收包错误率
-	InSendErrPkgRate float64 //所有内网网络接口发包错误率
+			sendByteAvg = float64(delta.SendByte) / diffTime    //平均每秒发送字节数
+			sendPkgAvg = float64(delta.SendPkg) / diffTime      //平均每秒发送包数
+			sendErrPkgAvg = float64(delta.SendErr) / diffTime   //平均每秒发送错误包数
+			sendDropPkgAvg = float64(delta.SendDrop) / diffTime //平均每秒发送丢包包数
+			if delta.SendPkg > 0 {
+				sendErrRate = float64(delta.SendErr) / float64(delta.SendPkg)   //一个周期发包错误率
+				sendDropRate = float64(delta.SendDrop) / float64(delta.SendPkg) //一个周期发包丢包率
+			}
 
-	//外网
-	OutRecvDropPkgAvg float64 //所有外网接口平均接收丢包数
-	OutSendDropPkgAvg float64 //所有外网接口平均发送丢包数
+			recvFifoAvg = float64(delta.RecvFifo) / diffTime
+			recvFrameAvg = float64(delta.RecvFrame) / diffTime
+			recvCompressedAvg = float64(delta.RecvCompressed) / diffTime
+			recvMulticastAvg = float64(delta.RecvMulticast) / diffTime
 
-	OutRecvErrPkgAvg float64 //所有外网接口平均接收错误数
-	OutSendErrPkgAvg float64 //所有外网接口平均发送错误数
+			sendFifoAvg = float64(delta.SendFifo) / diffTime
+			sendCollsAvg = float64(delta.SendColls) / diffTime
+			sendCarrierAvg = float64(delta.SendCarrier) / diffTime
+			sendCompressedAvg = float64(delta.SendCompressed) / diffTime
+		}
+	} //第一次采集，没有时间差，不计算
 
-	OutRecvPkgAvg float64 //所有外网接口平均接收包数
-	OutSendPkgAvg float64 //所有外网接口平均发送包数
+	n.RecvByte = stat.RecvByte
+	n.SendByte = stat.SendByte
 
-	OutRecvByteAvg float64 //所有外网接口平均接收字节数
-	OutSendByteAvg float64 //所有外网接口平均发送字节数
+	n.RecvPkg = stat.RecvPkg
+	n.SendPkg = stat.SendPkg
 
-	OutRecvDropPkgRate float64 //所有外网接口平均接收丢包率
-	OutSendDropPkgRate float64 //所有外网接口平均发送丢包率
+	n.RecvErr = stat.RecvErr
+	n.SendErr = stat.SendErr
 
-	OutRecvErrPkgRate float64 //所有外网接口平均接收错误率
-	OutSendErrPkgRate float64 //所有外网接口平均发送错误率
+	n.RecvDrop = stat.RecvDrop
+	n.SendDrop = stat.SendDrop
 
-	EthInMaxUseRate  float64 //内网网卡使用率
-	EthOutMaxUseRate float64 //外网网卡使用率
+	n.RecvFifo = stat.RecvFifo
+	n.RecvFrame = stat.RecvFrame
+	n.RecvCompressed = stat.RecvCompressed
+	n.RecvMulticast = stat.RecvMulticast
 
-	RecvSendDetail string //收发接口收发字节数详细信息
-	ModelDetail    string //网络接口型号带宽详细信息
+	n.SendFifo = stat.SendFifo
+	n.SendColls = stat.SendColls
+	n.SendCarrier = stat.SendCarrier
+	n.SendCompressed = stat.SendCompressed
 
-	/*
-		//外网网卡流入环比
-		OutRecvByteSum10Sum   float64 //外网网卡平均每秒接收字节累加和
-		OutRecvByteSum10Times int     //外网网卡平均每秒接收字节累加次数
-		OutRecvByteSum10      float64 //外网网卡流入10分钟环比
-		OutRecvByteSum10Last  int64
+	n.RecvPkgAvg = recvPkgAvg
+	n.SendPkgAvg = sendPkgAvg
 
-		OutRecvByteSum60Sum   float64 //外网网卡平均每秒接收字节累加和
-		OutRecvByteSum60Times int     //外网网卡平均每秒接收字节累加次数
-		OutRecvByteSum60      float64 //外网网卡流入60分钟环比
-		OutRecvByteSum60Last  int64
+	n.RecvByteAvg = recvByteAvg
+	n.SendByteAvg = sendByteAvg
 
-		OutRecvByteSumDaySum   float64 //外网网卡平均每秒接收字节累加和
-		OutRecvByteSumDayTimes int     //外网网卡平均每秒接收字节累加次数
-		OutRecvByteSumDay      float64 //外网网卡流入日同比
-		OutRecvByteSumDayLast  int64
-	*/
-}
+	n.RecvErrRate = recvErrRate
+	n.SendErrRate = sendErrRate
 
-// IsInEth 判断是否为内网
-func (n *Ifi) IsInEth(filterIps []string) bool {
-	if n.Ip == "" {
-		return false
-	}
+	n.RecvDropRate = recvDropRate
+	n.SendDropRate = sendDropRate
 
-	for _, ipPrefix := range filterIps {
-		utils.Trim(&ipPrefix)
-		if strings.HasPrefix(n.Ip, ipPrefix) {
-			return true
-		}
-	}
-	return false
+	n.RecvDropPkgAvg = recvDropPkgAvg
+	n.SendDropPkgAvg = sendDropPkgAvg
+
+	n.RecvErrPkgAvg = recvErrPkgAvg
+	n.SendErrPkgAvg = sendErrPkgAvg
+
+	n.RecvFifoAvg = recvFifoAvg
+	n.RecvFrameAvg = recvFrameAvg
+	n.RecvCompressedAvg = recvCompressedAvg
+	n.RecvMulticastAvg = recvMulticastAvg
+
+	n.SendFifoAvg = sendFifoAvg
+	n.SendCollsAvg = sendCollsAvg
+	n.SendCarrierAvg = sendCarrierAvg
+	n.SendCompressedAvg = sendCompressedAvg
+
+	n.Last = now
 }
 
 func FilterIPV4(ethIps []net.Addr) (ipv4 []string, ipv6 []string) {
@@ -144,449 +284,415 @@ func FilterIPV4(ethIps []net.Addr) (ipv4 []string, ipv6 []string) {
 	return
 }
 
+// IsIgnore 判断网卡是否忽略: 网卡名匹配IgnoreEth, 或任一地址属于IgnoreIP网段, 或没有IPv4地址且未开启IPV6
 func (n *NetWork) IsIgnore(ehtName string, ethIps []net.Addr) bool {
-	for _, eth := range n.IgnoreEth {
-		if strings.HasPrefix(ehtName, eth) {
-			return true
-		}
-	}
+	return n.classifier().isIgnore(ehtName, newAddrs(ethIps), n.IPV6)
+}
 
-	ignoreFlag := false
-	ipv4List, _ := FilterIPV4(ethIps)
+// IsInIP 判断网卡任一地址是否属于InIP网段
+func (n *NetWork) IsInIP(Ifi *Ifi) bool {
+	return parsePrefixes(n.InIP).anyIn(Ifi.Addrs)
+}
 
-	if len(ipv4List) == 1 {
-		for _, ignoreIP := range n.IgnoreIP {
-			utils.Trim(&ignoreIP)
-			if strings.HasPrefix(ipv4List[0], ignoreIP) {
-				ignoreFlag = true
-				break
-			}
-		}
+// IsIn 判断网卡是否为内网: 网卡名匹配InEth, 或任一地址属于InIP网段
+func (n *NetWork) IsIn(ifi *Ifi) bool {
+	return n.classifier().isIn(ifi)
+}
+
+// Collect 采集所有网卡流量, 采集完成后发布新的快照
+func (n *NetWork) Collect(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.applyPending()
+	stats, resolver, err := n.readHost()
+	if err != nil {
+		return err
+	}
+	now := n.now()
+	n.generation++
+	c := &collection{
+		now:     now,
+		snap:    newSnapshot(now, len(n.Current().IfiNames)),
+		cls:     n.classifier(),
+		shapers: n.shapers(),
+	}
+	if n.queues {
+		c.irqs = n.readInterrupts()
 	}
 
-	if len(ipv4List) > 1 {
-		for _, ip := range ipv4List {
-			for _, ignoreIP := range n.IgnoreIP {
-				if strings.HasPrefix(ip, ignoreIP) {
-					ignoreFlag = true
-					break
-				}
+	host := n.hostNetns()
+	var list []Netns
+	if n.netns || n.containers != nil {
+		list = n.listNetns(host, now)
+	}
+	c.byPeer, c.byNetns = n.containerPeers(list)
+	if err := n.collectNetns(ctx, c, Netns{Inode: host}, stats, resolver); err != nil {
+		return err
+	}
+	if n.netns {
+		for _, ns := range list {
+			stats, r, err := n.readNetns(ns, now)
+			if err != nil {
+				//命名空间可能在枚举后被删除, 或没有权限进入
+				continue
+			}
+			if err := n.collectNetns(ctx, c, ns, stats, r); err != nil {
+				return err
 			}
 		}
 	}
 
-	if len(ipv4List) == 0 {
-		ignoreFlag = !n.IPV6
+	//本次没有出现或不再监控的网卡
+	for key, ifi := range n.ifis {
+		if ifi.Generation != n.generation {
+			delete(n.ifis, key)
+			delete(n.linkCache, key)
+			delete(n.plateaus, key)
+			if ifi.Netns == "" {
+				forgetEthtoolStats(ifi.Name)
+			}
+			c.events = append(c.events, Event{Type: EventIfiRemoved, Name: key, Ifi: *ifi, Time: now})
+		}
 	}
 
-	return ignoreFlag
+	c.snap.finish(n.Current().IfiNames)
+	n.publish(c.snap, now)
+	n.events.publish(c.events...)
+	return nil
 }
 
-func (n *NetWork) IsInIP(Ifi *Ifi) bool {
-	if Ifi.Ip == "" {
-		return false
-	}
-
-	for _, ip := range n.InIP {
-		if strings.HasPrefix(Ifi.Ip, ip) {
-			return true
-		}
+// publish 发布采集的快照; 采集期间Watch收到的消息重新应用到新快照上, 以免被采集开始时读到的旧状态覆盖
+func (n *NetWork) publish(snap *Snapshot, now time.Time) {
+	n.pubMu.Lock()
+	defer n.pubMu.Unlock()
+	if len(n.pending) > 0 {
+		snap, _ = snap.apply(n.pending, now)
 	}
-	return false
+	n.snapshot.Store(snap)
 }
 
-func (n *NetWork) reset() {
-
-	//内网
-	n.InRecvByteAvg = 0
-	n.InSendByteAvg = 0
-
-	n.InRecvPkgAvg = 0
-	n.InSendPkgAvg = 0
-
-	n.InRecvErrPkgAvg = 0
-	n.InSendErrPkgAvg = 0
-
-	n.InRecvDropPkgAvg = 0
-	n.InSendDropPkgAvg = 0
-
-	n.InRecvDropPkgRate = 0
-	n.InSendDropPkgRate = 0
-
-	n.InRecvErrPkgRate = 0
-	n.InSendErrPkgRate = 0
-
-	//外网
-	n.OutRecvPkgAvg = 0
-	n.OutSendPkgAvg = 0
+// collection 一次采集的中间状态
+type collection struct {
+	now     time.Time
+	snap    *Snapshot
+	cls     *classifier
+	shapers map[int]Shaper
+	events  []Event
 
-	n.OutRecvByteAvg = 0
-	n.OutSendByteAvg = 0
+	byPeer  map[int]*Container    //本机veth ifindex到对端容器
+	byNetns map[uint64]*Container //命名空间inode到容器
 
-	n.OutSendErrPkgAvg = 0
-	n.OutRecvErrPkgAvg = 0
-
-	n.OutRecvDropPkgAvg = 0
-	n.OutSendDropPkgAvg = 0
-
-	n.OutRecvDropPkgRate = 0
-	n.OutSendDropPkgRate = 0
-
-	n.OutRecvErrPkgRate = 0
-	n.OutSendErrPkgRate = 0
+	irqs []irqStat //本周期的/proc/interrupts, 只在WithQueues时读取
 }
 
-func (n *NetWork) total(ifi *Ifi) {
-	if n.IsInIP(ifi) {
-		//内网
-		n.InRecvByteAvg += ifi.RecvByteAvg
-		n.InSendByteAvg += ifi.SendByteAvg
-
-		n.InRecvPkgAvg += ifi.RecvPkgAvg
-		n.InSendPkgAvg += ifi.SendPkgAvg
-
-		n.InRecvErrPkgAvg += ifi.RecvErrPkgAvg
-		n.InSendErrPkgAvg += ifi.SendErrPkgAvg
-
-		n.InRecvDropPkgAvg += ifi.RecvDropPkgAvg
-		n.InSendDropPkgAvg += ifi.SendDropPkgAvg
-
-		n.InRecvErrPkgRate += ifi.RecvErrRate
-		n.InSendErrPkgRate += ifi.SendErrRate
-
-		n.InRecvDropPkgRate += ifi.RecvDropRate
-		n.InSendDropPkgRate += ifi.SendDropRate
-	} else {
-		//外网
-		n.OutRecvPkgAvg += ifi.RecvPkgAvg
-		n.OutSendPkgAvg += ifi.SendPkgAvg
-
-		n.OutRecvByteAvg += ifi.RecvByteAvg
-		n.OutSendByteAvg += ifi.SendByteAvg
-
-		n.OutSendErrPkgAvg += ifi.SendErrPkgAvg
-		n.OutSendDropPkgAvg += ifi.SendDropPkgAvg
-
-		n.OutRecvErrPkgAvg += ifi.RecvErrPkgAvg
-		n.OutRecvDropPkgAvg += ifi.RecvDropPkgAvg
-
-		n.OutRecvErrPkgRate += ifi.RecvErrRate
-		n.OutSendErrPkgRate += ifi.SendErrRate
-
-		n.OutRecvDropPkgRate += ifi.RecvDropRate
-		n.OutSendDropPkgRate += ifi.SendDropRate
-	}
-}
-
-func (n *NetWork) Collect() error {
-	n.reset()
-
-	f, err := os.Open("/proc/net/dev")
-	if err != nil {
-		return err
+// collectNetns 采集一个命名空间内的网卡; 链路模式、元数据和tc限速只对agent所在的命名空间读取,
+// 因为/sys/class/net和netlink看到的都是agent所在的命名空间
+func (n *NetWork) collectNetns(ctx context.Context, c *collection, ns Netns, stats []devStat, resolver Resolver) error {
+	netns := ""
+	if ns.Pid != 0 || ns.Name != "" {
+		netns = ns.ID()
 	}
-	defer f.Close()
-	reader := bufio.NewReader(f)
+	isHost := netns == ""
 
-	for {
-		line, err := reader.ReadString('\n')
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
+	for _, stat := range stats {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
-		if !strings.Contains(line, ":") {
-			continue
-		}
-
-		fields := strings.Split(line, ":")
-		if len(fields) < 2 {
-			continue
-		}
-
-		ethName := fields[0]
-		utils.Trim(&ethName)
-
-		fields = strings.Fields(fields[1])
-		if len(fields) != 16 {
-			continue
-		}
-
-		recvByte, _ := strconv.ParseUint(fields[0], 10, 64)
-		recvPkg, _ := strconv.ParseUint(fields[1], 10, 64)
-		recvErr, _ := strconv.ParseUint(fields[2], 10, 64)
-		recvDrop, _ := strconv.ParseUint(fields[3], 10, 64)
-
-		sendByte, _ := strconv.ParseUint(fields[8], 10, 64)
-		sendPkg, _ := strconv.ParseUint(fields[9], 10, 64)
-		sendErr, _ := strconv.ParseUint(fields[10], 10, 64)
-		sendDrop, _ := strconv.ParseUint(fields[11], 10, 64)
+		ethName := stat.Name
 
 		//根据网卡名得到对应的网络接口
-		netIfi, err := net.InterfaceByName(ethName)
+		link, err := resolver.LinkByName(ethName)
 		if err != nil {
 			continue
 		}
 
-		var addrs []net.Addr
-		addrs, err = netIfi.Addrs()
-		if err != nil {
-			continue
-		}
-
-		if len(addrs) == 0 {
-			continue
+		var container *Container
+		if isHost {
+			container = c.byPeer[link.Index]
+		} else {
+			container = c.byNetns[ns.Inode]
 		}
 
-		if n.IsIgnore(ethName, addrs) {
-			_, exists := n.IfiMap[ethName]
-			if exists {
-				delete(n.IfiMap, ethName)
+		addrs := newAddrs(link.Addrs)
+		if isHost && container != nil {
+			//容器的veth在本机一侧通常没有地址, 只按网卡名忽略
+			if c.cls.ignoreEth.match(ethName) {
+				continue
 			}
-
-			n.IfiNames = []string{}
-			for eth, _ := range n.IfiMap {
-				n.IfiNames = append(n.IfiNames, eth)
+		} else {
+			if len(link.Addrs) == 0 {
+				continue
+			}
+			if c.cls.isIgnore(ethName, addrs, n.IPV6) {
+				continue
 			}
-			continue
 		}
 
-		_, exists := n.IfiMap[ethName]
-		if !exists {
-			n.IfiMap[ethName] = &Ifi{}
-			n.IfiNames = append(n.IfiNames, ethName)
+		key := ifiKey(netns, ethName)
+		ifi, exists := n.ifis[key]
+		if exists && ifi.Index != link.Index {
+			//同名网卡被删除后重建, 按新网卡处理
+			c.events = append(c.events, Event{Type: EventIfiRemoved, Name: key, Ifi: *ifi, Time: c.now})
+			exists = false
 		}
-		ifi, _ := n.IfiMap[ethName]
-
-		var (
-			recvByteAvg    float64
-			recvPkgAvg     float64
-			recvErrRate    float64
-			recvDropRate   float64
-			recvErrPkgAvg  float64
-			recvDropPkgAvg float64
-
-			sendByteAvg    float64
-			sendPkgAvg     float64
-			sendErrRate    float64
-			sendDropRate   float64
-			sendErrPkgAvg  float64
-			sendDropPkgAvg float64
-		)
-		now := time.Now().Unix()
-		diffTime := float64(now - ifi.Last)
-
-		if ifi.Last == 0 {
-			//第一次采集，没有时间差，不计算
-		} else {
-			if diffTime > 0 {
-				recvByteAvg = float64(recvByte-ifi.RecvByte) / diffTime    //平均每秒接收字节数
-				recvPkgAvg = float64(recvPkg-ifi.RecvPkg) / diffTime       //平均每秒接收包数
-				recvErrPkgAvg = float64(recvErr-ifi.RecvErr) / diffTime    //平均每秒接收错误数
-				recvDropPkgAvg = float64(recvDrop-ifi.RecvDrop) / diffTime //平均每秒接收丢包数
-				if recvPkg-ifi.RecvPkg > 0 {
-					recvErrRate = float64(recvErr-ifi.RecvErr) / float64(recvPkg-ifi.RecvPkg)    //一个周期收包错误率
-					recvDropRate = float64(recvDrop-ifi.RecvDrop) / float64(recvPkg-ifi.RecvPkg) //一个周期收包丢包率
-				}
-
-				sendByteAvg = float64(sendByte-ifi.SendByte) / diffTime    //平均每秒发送字节数
-				sendPkgAvg = float64(sendPkg-ifi.SendPkg) / diffTime       //平均每秒发送包数
-				sendErrPkgAvg = float64(sendErr-ifi.SendErr) / diffTime    //平均每秒发送错误包数
-				sendDropPkgAvg = float64(sendDrop-ifi.SendDrop) / diffTime //平均每秒发送丢包包数
-				if sendPkg-ifi.SendPkg > 0 {
-					sendErrRate = float64(sendErr-ifi.SendErr) / float64(sendPkg-ifi.SendPkg)    //一个周期发包错误率
-					sendDropRate = float64(sendDrop-ifi.SendDrop) / float64(sendPkg-ifi.SendPkg) //一个周期发包丢包率
-				}
-			}
+		if !exists {
+			ifi = &Ifi{}
+			n.ifis[key] = ifi
+			delete(n.plateaus, key)
 		}
+		prev := *ifi
 
 		ifi.Name = ethName
-		ifi.Ip = strings.Split(addrs[0].String(), "/")[0]
-
-		ifi.RecvByte = recvByte
-		ifi.SendByte = sendByte
-
-		ifi.RecvPkg = recvPkg
-		ifi.SendPkg = sendPkg
-
-		ifi.RecvErr = recvErr
-		ifi.SendErr = sendErr
-
-		ifi.RecvDrop = recvDrop
-		ifi.SendDrop = sendDrop
-
-		ifi.RecvPkgAvg = recvPkgAvg
-		ifi.SendPkgAvg = sendPkgAvg
-
-		ifi.RecvByteAvg = recvByteAvg
-		ifi.SendByteAvg = sendByteAvg
-
-		ifi.RecvErrRate = recvErrRate
-		ifi.SendErrRate = sendErrRate
-
-		ifi.RecvDropRate = recvDropRate
-		ifi.SendDropRate = sendDropRate
-
-		ifi.RecvDropPkgAvg = recvErrPkgAvg
-		ifi.SendDropPkgAvg = sendDropPkgAvg
-
-		ifi.RecvErrPkgAvg = recvDropPkgAvg
-		ifi.SendErrPkgAvg = sendErrPkgAvg
-
-		ifi.Last = now
+		ifi.Netns = netns
+		ifi.NetnsInode = ns.Inode
+		ifi.Index = link.Index
+		ifi.Addrs = addrs
+		ifi.Ip = primaryIP(addrs)
+		ifi.ContainerID, ifi.ContainerName, ifi.ContainerRuntime = "", "", ""
+		if container != nil {
+			ifi.ContainerID, ifi.ContainerName, ifi.ContainerRuntime = container.ID, container.Name, container.Runtime
+		}
+		ifi.update(stat, c.now)
+		ifi.Generation = n.generation
+		if !exists {
+			c.events = append(c.events, Event{Type: EventIfiAdded, Name: key, Ifi: *ifi, Time: c.now})
+		}
 
-		n.total(ifi)
-		n.RecvSendDetail += ifi.Ip + "=" + ifi.Name + "=(" + strconv.FormatFloat(recvByteAvg, 'f', 0, 64) + "|" +
-			strconv.FormatFloat(sendByteAvg, 'f', 0, 64) + ")$"
+		var shaper Shaper
+		if isHost {
+			mode := n.linkMode(ethName, link.Index, link.attrs)
+			ifi.Speed = mode.Speed
+			ifi.Duplex = mode.Duplex
+			ifi.Autoneg = mode.Autoneg
+			ifi.Port = mode.Port
+
+			meta := n.linkMeta(ethName, link.attrs)
+			ifi.CarrierFlaps = 0
+			if exists && meta.CarrierChanges >= ifi.CarrierChanges {
+				ifi.CarrierFlaps = meta.CarrierChanges - ifi.CarrierChanges
+			}
+			ifi.MTU = meta.MTU
+			ifi.MAC = meta.MAC
+			ifi.OperState = meta.OperState
+			ifi.CarrierChanges = meta.CarrierChanges
+			ifi.Driver = meta.Driver
+			ifi.BusInfo = meta.BusInfo
+			ifi.Type = meta.Type
+
+			topo := n.readTopology(ethName, meta.Type, ifi.Bond)
+			ifi.Master = topo.Master
+			ifi.Lower = topo.Lower
+			ifi.Carriers = topo.Carriers
+			ifi.Bond = topo.Bond
+
+			var interval float64
+			if !prev.Last.IsZero() {
+				interval = c.now.Sub(prev.Last).Seconds()
+			}
+			var stats []DriverStat
+			if (n.queues || n.driverCounters) && n.driverStats != nil {
+				stats, _ = n.driverStats.DriverStats(ethName)
+			}
+			if n.driverCounters {
+				ifi.DriverStats = filterDriverStats(stats, n.driverInclude)
+				ifi.DriverCounters = normalizeDriverStats(stats, prev.DriverCounters, interval)
+			}
+			if n.queues {
+				ifi.Queues = n.readQueues(ethName, ifi.BusInfo, stats, prev.Queues, interval, c.irqs)
+				ifi.RecvQueueImbalance = queueImbalance(ifi.Queues, QueueRecv)
+				ifi.SendQueueImbalance = queueImbalance(ifi.Queues, QueueSend)
+			}
 
-		cmd := fmt.Sprintf("/sbin/ethtool %s 2>/dev/null", ethName)
-		output, err := utils.Exec(cmd)
-		if err != nil {
-			continue
+			shaper = c.shapers[link.Index]
 		}
-		lines := strings.Split(output, "\n")
-		for _, line = range lines {
-			if strings.Contains(line, "Speed") {
-				fields = strings.Split(line, ":")
-				if len(fields) != 2 {
-					continue
-				}
-				field2 := fields[1]
-				utils.Trim(&field2)
-				field2 = strings.Replace(field2, "Mb/s", "", -1)
-				speed, err := strconv.ParseFloat(field2, 64) //Mb/s, 注意是小b
-				if err != nil {
-					continue
-				}
-				ifi.Speed = speed
-				if speed > 0 {
-					inEthUseRate := recvByteAvg * 8 * 100 / (speed * 1024 * 1024)
-					if inEthUseRate > n.EthInMaxUseRate {
-						n.EthInMaxUseRate = inEthUseRate
-					}
-					outEthUseRate := sendByteAvg * 8 * 100 / (speed * 1024 * 1024)
-					if outEthUseRate > n.EthOutMaxUseRate {
-						n.EthOutMaxUseRate = outEthUseRate
-					}
-				}
-				break
-			}
+		ifi.Speed = n.overrideSpeed(ethName, ifi.Speed)
+		ifi.updateUseRate()
+		n.applyLimit(ifi, key, shaper)
+		if exists {
+			c.events = append(c.events, changeEvents(key, &prev, ifi, c.now)...)
 		}
-		n.ModelDetail += fmt.Sprintf("%v|%v|%v$", ifi.Name, ifi.Ip, ifi.Speed)
+
+		cp := *ifi
+		c.snap.add(&cp, c.cls.isIn(&cp))
 	}
 	return nil
 }
 
-func NewNetwork(ignoreIP, ignoreEth, inIp, InEth []string) *NetWork {
-	return &NetWork{
-		IfiMap:    make(map[string]*Ifi),
-		IfiNames:  []string{},
-		IgnoreIP:  ignoreIP,
-		IgnoreEth: ignoreEth,
-		InIP:      inIp,
-		InEth:     InEth,
+func NewNetwork(ignoreIP, ignoreEth, inIp, InEth []string, opts ...Option) *NetWork {
+	n := &NetWork{
+		ifis:           make(map[string]*Ifi),
+		linkCache:      make(map[string]linkCacheEntry),
+		plateaus:       make(map[string]*ifiPlateau),
+		containerCache: make(map[uint64]*netnsContainer),
+		netnsLinks:     make(map[uint64]*netnsLinks),
+		IgnoreIP:       ignoreIP,
+		IgnoreEth:      ignoreEth,
+		InIP:           inIp,
+		InEth:          InEth,
+		fs:             procfs.NewFS(procfs.DefaultRoot),
+		resolver:       SystemResolver{},
+		now:            time.Now,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	if n.shaper == nil && n.fs.IsHost() {
+		n.shaper = NetlinkShaper{}
 	}
+	if n.netnsResolver == nil && n.fs.IsHost() {
+		n.netnsResolver = setnsResolver
+	}
+	if n.driverStats == nil && n.fs.IsHost() {
+		n.driverStats = EthtoolStats{}
+	}
+	n.snapshot.Store(newSnapshot(time.Time{}, 0))
+	return n
+}
+
+// Current 最近一次发布的快照, 快照不可修改, 读取无需加锁
+func (n *NetWork) Current() *Snapshot {
+	return n.snapshot.Load().(*Snapshot)
 }
 
 //  +++++ 整机指标 +++++
 
 // OutSendErrAvg 所有外网一个周期平均发包错误数
 func (n *NetWork) OutSendErrAvg() float64 {
-	return utils.FormatFloat(n.OutSendErrPkgAvg)
+	return utils.FormatFloat(n.Current().OutSendErrPkgAvg)
 }
 
 // OutSendDropAvg 所有外网一个周期平均发包丢包数
 func (n *NetWork) OutSendDropAvg() float64 {
-	return utils.FormatFloat(n.OutSendDropPkgAvg)
+	return utils.FormatFloat(n.Current().OutSendDropPkgAvg)
 }
 
 // OutRecvErrAvg 所有外网一个周期平均收包错误数
 func (n *NetWork) OutRecvErrAvg() float64 {
-	return utils.FormatFloat(n.OutRecvErrPkgAvg)
+	return utils.FormatFloat(n.Current().OutRecvErrPkgAvg)
 }
 
 // OutRecvDropAvg 所有外网一个周期平均收包丢包数
 func (n *NetWork) OutRecvDropAvg() float64 {
-	return utils.FormatFloat(n.OutRecvDropPkgAvg)
+	return utils.FormatFloat(n.Current().OutRecvDropPkgAvg)
 }
 
 // InSendPkgSumFunc 所有内网平均发包速率(pkg/s)
 func (n *NetWork) InSendPkgSumFunc() float64 {
-	return utils.FormatFloat(n.InSendPkgAvg)
+	return utils.FormatFloat(n.Current().InSendPkgAvg)
 }
 
 // InRecvPkgSumFunc 所有内网平均收包速率(pkg/s)
 func (n *NetWork) InRecvPkgSumFunc() float64 {
-	return utils.FormatFloat(n.InRecvPkgAvg)
+	return utils.FormatFloat(n.Current().InRecvPkgAvg)
 }
 
 // InRecvByteAvgFunc 所有内网平均网入带宽(byte/s)
 func (n *NetWork) InRecvByteAvgFunc() float64 {
-	return utils.FormatFloat(n.InRecvByteAvg)
+	return utils.FormatFloat(n.Current().InRecvByteAvg)
 }
 
 // InSendByteAvgFunc 所有内网平均网出带宽(byte/s)
 func (n *NetWork) InSendByteAvgFunc() float64 {
-	return utils.FormatFloat(n.InSendByteAvg)
+	return utils.FormatFloat(n.Current().InSendByteAvg)
 }
 
 // OutSendPkgAvgFunc 所有外网平均发包速度(pkg/s)
 func (n *NetWork) OutSendPkgAvgFunc() float64 {
-	return utils.FormatFloat(n.OutSendPkgAvg)
+	return utils.FormatFloat(n.Current().OutSendPkgAvg)
 }
 
 // OutRecvPkgAvgFunc 所有外网平均收包速度(pkg/s)
 func (n *NetWork) OutRecvPkgAvgFunc() float64 {
-	return utils.FormatFloat(n.OutRecvPkgAvg)
+	return utils.FormatFloat(n.Current().OutRecvPkgAvg)
 }
 
 // OutEthRecvByteAvgFunc 所有外网平均入带宽(byte/s)
 func (n *NetWork) OutEthRecvByteAvgFunc() float64 {
-	return utils.FormatFloat(n.OutRecvByteAvg)
+	return utils.FormatFloat(n.Current().OutRecvByteAvg)
 }
 
 // OutEthSendByteAvgFunc 所有外网平均出带宽(byte/s)
 func (n *NetWork) OutEthSendByteAvgFunc() float64 {
-	return utils.FormatFloat(n.OutSendByteAvg)
+	return utils.FormatFloat(n.Current().OutSendByteAvg)
 }
 
 // OutRecvErrPkgRateFun 所有外网网卡接收错误率
 func (n *NetWork) OutRecvErrPkgRateFun() float64 {
-	return utils.FormatFloat(n.OutRecvErrPkgRate)
+	return utils.FormatFloat(n.Current().OutRecvErrPkgRate)
 }
 
 // OutRecvDropPkgRateFun 所有外网网卡接收丢包率
 func (n *NetWork) OutRecvDropPkgRateFun() float64 {
-	return utils.FormatFloat(n.OutRecvDropPkgRate)
+	return utils.FormatFloat(n.Current().OutRecvDropPkgRate)
 }
 
 // OutSendErrPkgRateFun 所有外网网卡发送错误率
 func (n *NetWork) OutSendErrPkgRateFun() float64 {
-	return utils.FormatFloat(n.OutSendErrPkgRate)
+	return utils.FormatFloat(n.Current().OutSendErrPkgRate)
 }
 
 // OutSendDropPkgRateFun 所有外网网卡发送丢包率
 func (n *NetWork) OutSendDropPkgRateFun() float64 {
-	return utils.FormatFloat(n.OutSendDropPkgRate)
+	return utils.FormatFloat(n.Current().OutSendDropPkgRate)
+}
+
+// InRecvMaxUseRateFunc 所有内网网卡接收带宽最大使用率
+func (n *NetWork) InRecvMaxUseRateFunc() float64 {
+	return utils.FormatFloat(n.Current().InRecvMaxUseRate)
+}
+
+// InRecvAvgUseRateFunc 所有内网网卡接收带宽平均使用率
+func (n *NetWork) InRecvAvgUseRateFunc() float64 {
+	return utils.FormatFloat(n.Current().InRecvAvgUseRate)
+}
+
+// InSendMaxUseRateFunc 所有内网网卡发送带宽最大使用率
+func (n *NetWork) InSendMaxUseRateFunc() float64 {
+	return utils.FormatFloat(n.Current().InSendMaxUseRate)
+}
+
+// InSendAvgUseRateFunc 所有内网网卡发送带宽平均使用率
+func (n *NetWork) InSendAvgUseRateFunc() float64 {
+	return utils.FormatFloat(n.Current().InSendAvgUseRate)
+}
+
+// OutRecvMaxUseRateFunc 所有外网网卡接收带宽最大使用率
+func (n *NetWork) OutRecvMaxUseRateFunc() float64 {
+	return utils.FormatFloat(n.Current().OutRecvMaxUseRate)
+}
+
+// OutRecvAvgUseRateFunc 所有外网网卡接收带宽平均使用率
+func (n *NetWork) OutRecvAvgUseRateFunc() float64 {
+	return utils.FormatFloat(n.Current().OutRecvAvgUseRate)
+}
+
+// OutSendMaxUseRateFunc 所有外网网卡发送带宽最大使用率
+func (n *NetWork) OutSendMaxUseRateFunc() float64 {
+	return utils.FormatFloat(n.Current().OutSendMaxUseRate)
+}
+
+// OutSendAvgUseRateFunc 所有外网网卡发送带宽平均使用率
+func (n *NetWork) OutSendAvgUseRateFunc() float64 {
+	return utils.FormatFloat(n.Current().OutSendAvgUseRate)
 }
 
 // EthInMaxUseRateFunc 所有网卡入带宽最大使用率
 func (n *NetWork) EthInMaxUseRateFunc() float64 {
-	return utils.FormatFloat(n.EthInMaxUseRate)
+	return utils.FormatFloat(n.Current().EthInMaxUseRate)
 }
 
 // EthOutMaxUseRateFunc 所有网卡出带宽最大使用率
 func (n *NetWork) EthOutMaxUseRateFunc() float64 {
-	return utils.FormatFloat(n.EthOutMaxUseRate)
+	return utils.FormatFloat(n.Current().EthOutMaxUseRate)
 }
 
 // +++++ 单网卡 +++++
 
 // EthSendPkgAvgFunc 网卡平均发包速度(pkg/s)
 func (n *NetWork) EthSendPkgAvgFunc(args string) float64 {
-	ifi, err := n.GetIfiByIndex(args)
+	ifi, err := n.Lookup(args)
 	if err != nil {
 		return 0
 	}
@@ -595,7 +701,7 @@ func (n *NetWork) EthSendPkgAvgFunc(args string) float64 {
 
 // EthRecvPkgAvgFunc 网卡平均收包速率(pkg/s)
 func (n *NetWork) EthRecvPkgAvgFunc(args string) float64 {
-	ifi, err := n.GetIfiByIndex(args)
+	ifi, err := n.Lookup(args)
 	if err != nil {
 		return 0
 	}
@@ -604,7 +710,7 @@ func (n *NetWork) EthRecvPkgAvgFunc(args string) float64 {
 
 // EthRecvByteAvgFunc 网卡平均接收字节速率(byte/s)
 func (n *NetWork) EthRecvByteAvgFunc(args string) float64 {
-	ifi, err := n.GetIfiByIndex(args)
+	ifi, err := n.Lookup(args)
 	if err != nil {
 		return 0
 	}
@@ -613,7 +719,7 @@ func (n *NetWork) EthRecvByteAvgFunc(args string) float64 {
 
 // EthSendByteAvgFunc 网卡平均发送字节速率(byte/s)
 func (n *NetWork) EthSendByteAvgFunc(args string) float64 {
-	ifi, err := n.GetIfiByIndex(args)
+	ifi, err := n.Lookup(args)
 	if err != nil {
 		return 0
 	}
@@ -621,7 +727,7 @@ func (n *NetWork) EthSendByteAvgFunc(args string) float64 {
 }
 
 func (n *NetWork) GetIfiBandwidthLimitStatusByIp(ip string) (isLimit int) {
-	for _, ethInfo := range n.IfiMap {
+	for _, ethInfo := range n.Current().IfiMap {
 		if ethInfo.Ip == ip {
 			return ethInfo.BandwidthLimit
 		}
@@ -629,29 +735,19 @@ func (n *NetWork) GetIfiBandwidthLimitStatusByIp(ip string) (isLimit int) {
 	return isLimit
 }
 
+// GetIfiByIndex 按网卡在IfiNames中的位置查找网卡
 func (n *NetWork) GetIfiByIndex(args string) (*Ifi, error) {
-	index, err := strconv.Atoi(args)
-	if err != nil {
-		return nil, err
-	}
-	if index < 0 {
-		return nil, errors.New("invalid index")
-	}
-	length := len(n.IfiNames)
-	if index > length-1 {
-		return nil, errors.New("invalid index")
-	}
-	key := n.IfiNames[index]
-	ifi, exists := n.IfiMap[key]
-	if exists {
-		return ifi, nil
-	}
-	return nil, errors.New("key not found")
+	return n.Current().GetIfiByIndex(args)
+}
+
+// Lookup 按选择器查找网卡, 选择器格式见Snapshot.Lookup
+func (n *NetWork) Lookup(selector string) (*Ifi, error) {
+	return n.Current().Lookup(selector)
 }
 
 // EthRecvErrRateFunc 网卡收包错误率
 func (n *NetWork) EthRecvErrRateFunc(args string) float64 {
-	ifi, err := n.GetIfiByIndex(args)
+	ifi, err := n.Lookup(args)
 	if err != nil {
 		return 0
 	}
@@ -660,7 +756,7 @@ func (n *NetWork) EthRecvErrRateFunc(args string) float64 {
 
 // EthRecvDropRateFunc 网卡收包丢包率
 func (n *NetWork) EthRecvDropRateFunc(args string) float64 {
-	ifi, err := n.GetIfiByIndex(args)
+	ifi, err := n.Lookup(args)
 	if err != nil {
 		return 0
 	}
@@ -669,7 +765,7 @@ func (n *NetWork) EthRecvDropRateFunc(args string) float64 {
 
 // EthSendErrRateFunc 网卡发包错误率
 func (n *NetWork) EthSendErrRateFunc(args string) float64 {
-	ifi, err := n.GetIfiByIndex(args)
+	ifi, err := n.Lookup(args)
 	if err != nil {
 		return 0
 	}
@@ -678,7 +774,7 @@ func (n *NetWork) EthSendErrRateFunc(args string) float64 {
 
 // EthSendDropRateFunc 网卡发包丢包率
 func (n *NetWork) EthSendDropRateFunc(args string) float64 {
-	ifi, err := n.GetIfiByIndex(args)
+	ifi, err := n.Lookup(args)
 	if err != nil {
 		return 0
 	}
@@ -687,116 +783,40 @@ func (n *NetWork) EthSendDropRateFunc(args string) float64 {
 
 // EthSpeedFunc 网卡速率(Mb/s)
 func (n *NetWork) EthSpeedFunc(args string) float64 {
-	ifi, err := n.GetIfiByIndex(args)
+	ifi, err := n.Lookup(args)
 	if err != nil {
 		return 0
 	}
 	return utils.FormatFloat(ifi.Speed)
 }
 
-//EthModelFunc 机器网卡信息
-func (n *NetWork) EthModelFunc(args string) string {
-	return n.ModelDetail
-}
-
-// EthByteSetFunc 所有网卡流量信息
-func (n *NetWork) EthByteSetFunc(args string) string {
-	return n.RecvSendDetail
-}
-
-/*
-func (n *NetWork) AddRecvBytes(bytes float64) {
-	n.OutRecvByteSum10Sum += bytes
-	n.OutRecvByteSum10Times++
-	n.OutRecvByteSum60Sum += bytes
-	n.OutRecvByteSum60Times++
-	n.OutRecvByteSumDaySum += bytes
-	n.OutRecvByteSumDayTimes++
-}*/
-
-/*
-func (n *NetWork) ResetRecvSum10() {
-	n.OutRecvByteSum10Sum = 0
-	n.OutRecvByteSum10Times = 0
-}
-
-func (n *NetWork) ResetRecvSum60() {
-	n.OutRecvByteSum60Sum = 0
-	n.OutRecvByteSum60Times = 0
-}
-
-func (n *NetWork) ResetRecvSumDay() {
-	n.OutRecvByteSumDaySum = 0
-	n.OutRecvByteSumDayTimes = 0
-}*/
-
-/*
-//外网网卡流入，10分钟环比
-func (n *NetWork) OutEthRecv10Func(args string) float64 {
-	if time.Now().Unix()-n.OutRecvByteSum10Last < 600 {
-		return 0
-	}
-	if n.OutRecvByteSum10Times <= 0 {
+// EthRecvUseRateFunc 网卡接收带宽使用率(%)
+func (n *NetWork) EthRecvUseRateFunc(args string) float64 {
+	ifi, err := n.Lookup(args)
+	if err != nil {
 		return 0
 	}
-	var ret float64 = 0
-	avg := n.OutRecvByteSum10Sum / float64(n.OutRecvByteSum10Times)
-	//到这
-	if n.OutRecvByteSum10 == 0 && avg != 0 {
-		ret = 100
-	} else {
-		ret = (avg - n.OutRecvByteSum10) / n.OutRecvByteSum10 * 100
-	}
-	n.OutRecvByteSum10 = avg
-	n.OutRecvByteSum10Last = time.Now().Unix()
-	n.ResetRecvSum10()
-	return g.ParseFloat(ret)
+	return utils.FormatFloat(ifi.RecvUseRate)
 }
 
-//外网网卡流入，60分钟环比
-func (n *NetWork) OutEthRecv60Func(args string) float64 {
-	if time.Now().Unix()-n.OutRecvByteSum60Last < 3600 {
-		return 0
-	}
-	if n.OutRecvByteSum60Times <= 0 {
+// EthSendUseRateFunc 网卡发送带宽使用率(%)
+func (n *NetWork) EthSendUseRateFunc(args string) float64 {
+	ifi, err := n.Lookup(args)
+	if err != nil {
 		return 0
 	}
-	var ret float64 = 0
-	avg := n.OutRecvByteSum60Sum / float64(n.OutRecvByteSum60Times)
-	//到这
-	if n.OutRecvByteSum60 == 0 && avg != 0 {
-		ret = 100
-	} else {
-		ret = (avg - n.OutRecvByteSum60) / n.OutRecvByteSum60 * 100
-	}
-	n.OutRecvByteSum60 = avg
-	n.OutRecvByteSum60Last = time.Now().Unix()
-	n.ResetRecvSum60()
-	return g.ParseFloat(ret)
+	return utils.FormatFloat(ifi.SendUseRate)
 }
 
-//外网网卡流入，日环比
-func (n *NetWork) OutEthRecvDayFunc(args string) float64 {
-	if time.Now().Unix()-n.OutRecvByteSumDayLast < 3600 {
-		return 0
-	}
-	if n.OutRecvByteSumDayTimes <= 0 {
-		return 0
-	}
-	var ret float64 = 0
-	avg := n.OutRecvByteSumDaySum / float64(n.OutRecvByteSumDayTimes)
-	//到这
-	if n.OutRecvByteSumDay == 0 && avg != 0 {
-		ret = 100
-	} else {
-		ret = (avg - n.OutRecvByteSumDay) / n.OutRecvByteSumDay * 100
-	}
-	n.OutRecvByteSumDay = avg
-	n.OutRecvByteSumDayLast = time.Now().Unix()
-	n.ResetRecvSumDay()
-	return g.ParseFloat(ret)
+// EthModelFunc 机器网卡信息
+func (n *NetWork) EthModelFunc(args string) string {
+	return encodeString(LegacyModelEncoder{}, n.Current().Inventory())
+}
+
+// EthByteSetFunc 所有网卡流量信息
+func (n *NetWork) EthByteSetFunc(args string) string {
+	return encodeString(LegacyByteEncoder{}, n.Current().Inventory())
 }
-*/
 
 func ConnNumByPort(port string) string {
 	return utils.ExecOutput("netstat -pnt |grep ':" + port + "\\b' |wc -l")
@@ -0,0 +1,264 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/procfs"
+)
+
+func TestParseDev(t *testing.T) {
+	f, err := os.Open("testdata/host/t1/proc/net/dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stats, err := parseDev(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 4 {
+		t.Fatalf("got %d stats, want 4", len(stats))
+	}
+
+	eth0 := stats[1]
+	want := devStat{
+		Name:     "eth0",
+		RecvByte: 2000000, RecvPkg: 3000, RecvErr: 10, RecvDrop: 20,
+		RecvFifo: 30, RecvFrame: 50, RecvMulticast: 400,
+		SendByte: 7000000, SendPkg: 6000, SendErr: 4, SendDrop: 8,
+		SendFifo: 20, SendColls: 70, SendCarrier: 10,
+		width: counterProcDev,
+	}
+	if eth0 != want {
+		t.Fatalf("got %+v, want %+v", eth0, want)
+	}
+}
+
+func TestParseIPAddr(t *testing.T) {
+	r, err := LoadIPAddr("testdata/host/ip-addr.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := r.LinkByName("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Index != 2 || len(link.Addrs) != 2 {
+		t.Fatalf("unexpected link: %+v", link)
+	}
+	if link.Addrs[0].String() != "203.0.113.10/24" {
+		t.Fatalf("got addr %v", link.Addrs[0])
+	}
+
+	if _, err := r.LinkByName("eth9"); err != ErrLinkNotFound {
+		t.Fatalf("got %v, want %v", err, ErrLinkNotFound)
+	}
+}
+
+func newFixtureNetwork(t *testing.T, root string) (*NetWork, *time.Time) {
+	r, err := LoadIPAddr("testdata/host/ip-addr.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1600000000, 0)
+	n := NewNetwork([]string{}, []string{"docker", "lo"}, []string{"10."}, []string{},
+		WithRoot(root), WithResolver(r))
+	n.now = func() time.Time { return now }
+	return n, &now
+}
+
+func TestCollect(t *testing.T) {
+	n, now := newFixtureNetwork(t, "testdata/host/t0")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	names := append([]string{}, n.Current().IfiNames...)
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "eth0" || names[1] != "eth1" {
+		t.Fatalf("got interfaces %v", names)
+	}
+	if n.Current().IfiMap["eth0"].RecvByteAvg != 0 {
+		t.Fatalf("first collect should not compute rates")
+	}
+
+	*now = now.Add(10 * time.Second)
+	n.fs = procfs.NewFS("testdata/host/t1")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	eth0 := n.Current().IfiMap["eth0"]
+	if eth0.Ip != "203.0.113.10" {
+		t.Fatalf("got ip %v", eth0.Ip)
+	}
+	if eth0.RecvFrame != 50 || eth0.SendColls != 70 || eth0.SendCarrier != 10 {
+		t.Fatalf("unexpected raw counters: %+v", eth0.stat())
+	}
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"RecvByteAvg", eth0.RecvByteAvg, 100000},
+		{"SendByteAvg", eth0.SendByteAvg, 200000},
+		{"RecvPkgAvg", eth0.RecvPkgAvg, 100},
+		{"SendPkgAvg", eth0.SendPkgAvg, 200},
+		{"RecvErrPkgAvg", eth0.RecvErrPkgAvg, 1},
+		{"RecvDropPkgAvg", eth0.RecvDropPkgAvg, 2},
+		{"SendErrPkgAvg", eth0.SendErrPkgAvg, 0.4},
+		{"SendDropPkgAvg", eth0.SendDropPkgAvg, 0.8},
+		{"RecvErrRate", eth0.RecvErrRate, 0.01},
+		{"RecvDropRate", eth0.RecvDropRate, 0.02},
+		{"SendErrRate", eth0.SendErrRate, 0.002},
+		{"SendDropRate", eth0.SendDropRate, 0.004},
+		{"RecvFifoAvg", eth0.RecvFifoAvg, 3},
+		{"RecvFrameAvg", eth0.RecvFrameAvg, 5},
+		{"RecvCompressedAvg", eth0.RecvCompressedAvg, 0},
+		{"RecvMulticastAvg", eth0.RecvMulticastAvg, 40},
+		{"SendFifoAvg", eth0.SendFifoAvg, 2},
+		{"SendCollsAvg", eth0.SendCollsAvg, 7},
+		{"SendCarrierAvg", eth0.SendCarrierAvg, 1},
+		{"SendCompressedAvg", eth0.SendCompressedAvg, 0},
+		{"OutRecvByteAvg", n.Current().OutRecvByteAvg, 100000},
+		{"InRecvByteAvg", n.Current().InRecvByteAvg, 10000},
+		{"InSendByteAvg", n.Current().InSendByteAvg, 5000},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestCollectMissingRoot(t *testing.T) {
+	n, _ := newFixtureNetwork(t, "testdata/missing")
+	if err := n.Collect(context.Background()); err == nil {
+		t.Fatal("expected error for missing /proc/net/dev")
+	}
+}
+
+func TestCollectSubSecond(t *testing.T) {
+	n, now := newFixtureNetwork(t, "testdata/host/t0")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	*now = now.Add(250 * time.Millisecond)
+	n.fs = procfs.NewFS("testdata/host/t1")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.Current().IfiMap["eth0"].RecvByteAvg; got != 4000000 {
+		t.Fatalf("RecvByteAvg = %v, want 4000000", got)
+	}
+
+	//同一时刻重复采集保留上次结果
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.Current().IfiMap["eth0"].RecvByteAvg; got != 4000000 {
+		t.Fatalf("RecvByteAvg after zero interval = %v, want 4000000", got)
+	}
+}
+
+func TestParseDevLine(t *testing.T) {
+	stat, ok := parseDevLine([]byte("  eth0: 18446744073709551615 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16"))
+	if !ok || stat.Name != "eth0" || stat.RecvByte != math.MaxUint64 || stat.SendCompressed != 16 {
+		t.Fatalf("got %+v, %v", stat, ok)
+	}
+	//溢出和非法字符按0处理
+	stat, ok = parseDevLine([]byte("eth1:18446744073709551616 x 3 4 5 6 7 8 9 10 11 12 13 14 15 16"))
+	if !ok || stat.RecvByte != 0 || stat.RecvPkg != 0 || stat.RecvErr != 3 {
+		t.Fatalf("got %+v, %v", stat, ok)
+	}
+	for _, line := range []string{
+		"Inter-|   Receive",
+		" face |bytes    packets errs drop fifo frame compressed multicast|bytes",
+		"eth2: 1 2 3",
+		"eth3: 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17",
+		": 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16",
+	} {
+		if _, ok := parseDevLine([]byte(line)); ok {
+			t.Errorf("%q should not parse", line)
+		}
+	}
+}
+
+// newScaleNetwork 生成count个网卡的现场数据, 其中一半没有地址而被忽略, 模拟大量veth的主机
+func newScaleNetwork(tb testing.TB, count int) *NetWork {
+	root := tb.TempDir()
+	dir := filepath.Join(root, "proc", "net")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		tb.Fatal(err)
+	}
+	var dev strings.Builder
+	dev.WriteString("Inter-|   Receive                                                |  Transmit\n")
+	dev.WriteString(" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n")
+	r := StaticResolver{}
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("veth%05d", i)
+		fmt.Fprintf(&dev, "%s: 123456789 12345 0 0 0 0 0 0 987654321 54321 0 0 0 0 0 0\n", name)
+		link := &Link{Index: i + 2, Name: name}
+		if i%2 == 0 {
+			link.Addrs = []net.Addr{&net.IPNet{IP: net.IPv4(10, byte(i>>16), byte(i>>8), byte(i)), Mask: net.CIDRMask(8, 32)}}
+		}
+		r[name] = link
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dev"), []byte(dev.String()), 0644); err != nil {
+		tb.Fatal(err)
+	}
+
+	now := time.Unix(1600000000, 0)
+	n := NewNetwork([]string{}, []string{"lo"}, []string{"10."}, []string{}, WithRoot(root), WithResolver(r))
+	n.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	if err := n.Collect(context.Background()); err != nil {
+		tb.Fatal(err)
+	}
+	return n
+}
+
+// TestCollectScalesLinearly 网卡数增加10倍时, 每次采集的内存分配次数也应只增加约10倍
+func TestCollectScalesLinearly(t *testing.T) {
+	allocs := func(count int) float64 {
+		n := newScaleNetwork(t, count)
+		return testing.AllocsPerRun(5, func() {
+			if err := n.Collect(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	small, large := allocs(100), allocs(1000)
+	if large > small*15 {
+		t.Fatalf("allocs per collect: 100 ifaces %v, 1000 ifaces %v", small, large)
+	}
+}
+
+func BenchmarkCollect(b *testing.B) {
+	for _, count := range []int{10, 1000, 10000} {
+		b.Run(fmt.Sprintf("ifaces=%d", count), func(b *testing.B) {
+			n := newScaleNetwork(b, count)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := n.Collect(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
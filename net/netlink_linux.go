@@ -0,0 +1,73 @@
+package net
+
+import (
+	"os"
+	"syscall"
+)
+
+// netlinkConn NETLINK_ROUTE连接, 用于发送NLM_F_DUMP请求
+type netlinkConn struct {
+	fd  int
+	sa  *syscall.SockaddrNetlink
+	seq uint32
+	buf []byte
+}
+
+func dialNetlink() (*netlinkConn, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return &netlinkConn{fd: fd, sa: sa, buf: make([]byte, 8*os.Getpagesize())}, nil
+}
+
+func (c *netlinkConn) close() error {
+	return syscall.Close(c.fd)
+}
+
+// dump 发送一次NLM_F_DUMP请求, body为请求头之后的消息体, 每条回复消息调用一次fn
+func (c *netlinkConn) dump(typ uint16, body []byte, fn func(typ uint16, data []byte)) error {
+	c.seq++
+	req := make([]byte, syscall.NLMSG_HDRLEN+len(body))
+	nativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	nativeEndian.PutUint16(req[4:6], typ)
+	nativeEndian.PutUint16(req[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	nativeEndian.PutUint32(req[8:12], c.seq)
+	copy(req[syscall.NLMSG_HDRLEN:], body)
+	if err := syscall.Sendto(c.fd, req, 0, c.sa); err != nil {
+		return err
+	}
+
+	for {
+		n, _, err := syscall.Recvfrom(c.fd, c.buf, 0)
+		if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(c.buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != c.seq {
+				continue
+			}
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return nil
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if errno := -int32(nativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+						return syscall.Errno(errno)
+					}
+				}
+				return nil
+			}
+			fn(m.Header.Type, m.Data)
+		}
+	}
+}
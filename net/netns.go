@@ -0,0 +1,236 @@
+package net
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// netnsRefresh 缓存的pid到命名空间映射和命名空间网络接口的最长沿用时间,
+// 进程unshare/setns切换命名空间、网卡不变而地址变化时最迟在这个时间后发现
+const netnsRefresh = time.Minute
+
+var ErrNetnsUnreadable = errors.New("network namespace is not readable")
+
+// Netns 网络命名空间
+type Netns struct {
+	Inode uint64 //命名空间inode, 唯一标识命名空间
+	Name  string //ip netns的名称, 没有时为空
+	Pid   int    //命名空间内pid最小的进程, 没有进程时为0
+	Path  string //用于setns的路径, /var/run/netns/<name>或/proc/<pid>/ns/net
+}
+
+// ID 命名空间标识: 有名称时为名称, 否则为net:[inode]
+func (ns Netns) ID() string {
+	if ns.Name != "" {
+		return ns.Name
+	}
+	return "net:[" + strconv.FormatUint(ns.Inode, 10) + "]"
+}
+
+// netnsLinks 缓存的命名空间网络接口, 网卡没有变化时沿用, 不必每周期setns查询
+type netnsLinks struct {
+	names    []string  //查询时命名空间内的网卡, 与/proc/<pid>/net/dev中的顺序相同
+	resolver Resolver  //查询结果
+	at       time.Time //查询时间
+}
+
+// ifiKey 网卡在IfiMap中的key: 本机命名空间为网卡名, 其他命名空间为<命名空间标识>/<网卡名>
+func ifiKey(netns, name string) string {
+	if netns == "" {
+		return name
+	}
+	return netns + "/" + name
+}
+
+// Key 网卡在IfiMap中的key
+func (n *Ifi) Key() string {
+	return ifiKey(n.Netns, n.Name)
+}
+
+// parseNsLink 解析/proc/<pid>/ns/net链接的内容 net:[4026531992]
+func parseNsLink(link string) (uint64, bool) {
+	if !strings.HasPrefix(link, "net:[") || !strings.HasSuffix(link, "]") {
+		return 0, false
+	}
+	inode, err := strconv.ParseUint(link[len("net:["):len(link)-1], 10, 64)
+	return inode, err == nil
+}
+
+// nsInode 读取/proc/<pid>/ns/net链接得到命名空间inode
+func (n *NetWork) nsInode(pid string) (uint64, bool) {
+	link, err := os.Readlink(n.fs.Proc(pid, "ns", "net"))
+	if err != nil {
+		return 0, false
+	}
+	return parseNsLink(link)
+}
+
+// hostNetns agent所在的命名空间
+func (n *NetWork) hostNetns() uint64 {
+	inode, _ := n.nsInode("self")
+	return inode
+}
+
+// listNetns 枚举/proc/*/ns/net和/var/run/netns中的命名空间, 不含agent所在的命名空间, 按inode排序;
+// 已知pid的命名空间沿用上次读取的结果, 只重新确认每个命名空间选中的pid, 不一致时完整扫描
+func (n *NetWork) listNetns(host uint64, now time.Time) []Netns {
+	full := n.netnsPids == nil || now.Sub(n.netnsPidsAt) >= netnsRefresh
+	byInode, ok := n.scanNetnsPids(host, !full)
+	if !ok {
+		byInode, _ = n.scanNetnsPids(host, false)
+		full = true
+	}
+	if full {
+		n.netnsPidsAt = now
+	}
+
+	dir := n.fs.Path("var", "run", "netns")
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, e := range entries {
+			path := dir + "/" + e.Name()
+			inode, err := fileInode(path)
+			if err != nil || inode == host {
+				continue
+			}
+			ns, exists := byInode[inode]
+			if !exists {
+				ns = &Netns{Inode: inode}
+				byInode[inode] = ns
+			}
+			//有名称的命名空间优先通过bind mount切换, 不依赖进程是否还在
+			ns.Name, ns.Path = e.Name(), path
+		}
+	}
+
+	list := make([]Netns, 0, len(byInode))
+	for _, ns := range byInode {
+		list = append(list, *ns)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Inode < list[j].Inode })
+
+	for inode := range n.netnsLinks {
+		if _, exists := byInode[inode]; !exists {
+			delete(n.netnsLinks, inode)
+		}
+	}
+	return list
+}
+
+// scanNetnsPids 按/proc/*/ns/net把进程归到命名空间, 每个命名空间取pid最小的进程;
+// cached为true时已知pid沿用n.netnsPids, 选中的pid已不在原命名空间时返回false
+func (n *NetWork) scanNetnsPids(host uint64, cached bool) (map[uint64]*Netns, bool) {
+	byInode := make(map[uint64]*Netns)
+	pids := make(map[int]uint64)
+
+	entries, err := os.ReadDir(n.fs.Proc())
+	if err != nil {
+		n.netnsPids = pids
+		return byInode, true
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		inode, exists := n.netnsPids[pid]
+		if !cached || !exists {
+			if inode, exists = n.nsInode(e.Name()); !exists {
+				continue
+			}
+		}
+		pids[pid] = inode
+		if inode == host {
+			continue
+		}
+		if ns, exists := byInode[inode]; exists && ns.Pid < pid {
+			continue
+		}
+		byInode[inode] = &Netns{Inode: inode, Pid: pid, Path: n.fs.Proc(e.Name(), "ns", "net")}
+	}
+
+	if cached {
+		//pid可能被复用或进程切换了命名空间
+		for inode, ns := range byInode {
+			if cur, ok := n.nsInode(strconv.Itoa(ns.Pid)); !ok || cur != inode {
+				return nil, false
+			}
+		}
+	}
+	n.netnsPids = pids
+	return byInode, true
+}
+
+// readNetns 读取命名空间内的网卡计数和网络接口:
+// 有进程时读/proc/<pid>/net/dev, 只有名称时在锁定的线程中setns后读取
+func (n *NetWork) readNetns(ns Netns, now time.Time) ([]devStat, Resolver, error) {
+	if n.netnsResolver == nil {
+		return nil, nil, ErrNetnsUnreadable
+	}
+
+	var stats []devStat
+	var err error
+	if ns.Pid != 0 {
+		stats, err = n.readDev(n.fs.Proc(strconv.Itoa(ns.Pid), "net", "dev"))
+	} else if !n.fs.IsHost() {
+		return nil, nil, ErrNetnsUnreadable
+	} else {
+		err = withNetns(ns.Path, func() error {
+			var err error
+			stats, err = n.readDev("/proc/thread-self/net/dev")
+			return err
+		})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := n.netnsLinksResolver(ns, stats, now)
+	return stats, r, err
+}
+
+// netnsLinksResolver 命名空间内的网络接口: 网卡与上次查询时相同且未超过netnsRefresh时沿用缓存,
+// 命名空间新出现或网卡有增删改名时才通过netnsResolver重新查询
+func (n *NetWork) netnsLinksResolver(ns Netns, stats []devStat, now time.Time) (Resolver, error) {
+	names := make([]string, len(stats))
+	for i, stat := range stats {
+		names[i] = stat.Name
+	}
+	if cached, exists := n.netnsLinks[ns.Inode]; exists && now.Sub(cached.at) < netnsRefresh && equalStrings(cached.names, names) {
+		return cached.resolver, nil
+	}
+
+	r, err := n.netnsResolver(ns)
+	if err != nil {
+		delete(n.netnsLinks, ns.Inode)
+		return nil, err
+	}
+	n.netnsLinks[ns.Inode] = &netnsLinks{names: names, resolver: r, at: now}
+	return r, nil
+}
+
+// equalStrings 两个字符串切片的元素和顺序是否相同
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readDev 读取并解析/proc/net/dev格式的文件
+func (n *NetWork) readDev(path string) ([]devStat, error) {
+	f, err := n.fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseDev(f)
+}
@@ -0,0 +1,105 @@
+package net
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// fileInode 文件的inode, 对/var/run/netns下的bind mount即为命名空间inode
+func fileInode(path string) (uint64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, ErrNetnsUnreadable
+	}
+	return st.Ino, nil
+}
+
+// sysSetns 各架构setns的系统调用号, syscall包中没有定义
+var sysSetns = map[string]uintptr{
+	"386":      346,
+	"amd64":    308,
+	"arm":      375,
+	"arm64":    268,
+	"loong64":  268,
+	"mips":     4344,
+	"mipsle":   4344,
+	"mips64":   5303,
+	"mips64le": 5303,
+	"ppc64":    350,
+	"ppc64le":  350,
+	"riscv64":  268,
+	"s390x":    339,
+}[runtime.GOARCH]
+
+func setns(fd uintptr) error {
+	if sysSetns == 0 {
+		return syscall.ENOSYS
+	}
+	if _, _, errno := syscall.Syscall(sysSetns, fd, syscall.CLONE_NEWNET, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// withNetns 在单独的goroutine中锁定线程, 切换到path对应的命名空间执行fn后切回, 调用方goroutine的线程不受影响;
+// 切不回时不解锁线程, 该goroutine结束后运行时会销毁这个线程, 不会污染其他goroutine
+func withNetns(path string, fn func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		orig, err := os.Open("/proc/thread-self/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- err
+			return
+		}
+		defer orig.Close()
+
+		target, err := os.Open(path)
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- err
+			return
+		}
+		defer target.Close()
+
+		if err := setns(target.Fd()); err != nil {
+			runtime.UnlockOSThread()
+			done <- err
+			return
+		}
+		err = fn()
+		if setns(orig.Fd()) == nil {
+			runtime.UnlockOSThread()
+		}
+		done <- err
+	}()
+	return <-done
+}
+
+// setnsResolver 在命名空间内一次性读取所有网络接口
+func setnsResolver(ns Netns) (Resolver, error) {
+	r := StaticResolver{}
+	err := withNetns(ns.Path, func() error {
+		ifis, err := net.Interfaces()
+		if err != nil {
+			return err
+		}
+		for _, ifi := range ifis {
+			addrs, err := ifi.Addrs()
+			if err != nil {
+				continue
+			}
+			r[ifi.Name] = &Link{Index: ifi.Index, Name: ifi.Name, Addrs: addrs}
+		}
+		return nil
+	})
+	return r, err
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package net
+
+import (
+	"errors"
+)
+
+var errNetnsUnsupported = errors.New("network namespaces are only supported on linux")
+
+func fileInode(path string) (uint64, error) {
+	return 0, errNetnsUnsupported
+}
+
+func withNetns(path string, fn func() error) error {
+	return errNetnsUnsupported
+}
+
+func setnsResolver(ns Netns) (Resolver, error) {
+	return nil, errNetnsUnsupported
+}
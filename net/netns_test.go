@@ -0,0 +1,187 @@
+package net
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/procfs"
+)
+
+func newNetnsNetwork(t *testing.T) (*NetWork, *time.Time) {
+	root := "testdata/netns"
+	n, now := newFixtureNetwork(t, root)
+	WithNetns()(n)
+	WithNetnsResolver(func(ns Netns) (Resolver, error) {
+		return LoadIPAddr(filepath.Join(root, "ip-addr-"+strconv.FormatUint(ns.Inode, 10)+".txt"))
+	})(n)
+	return n, now
+}
+
+func TestListNetns(t *testing.T) {
+	n, _ := newNetnsNetwork(t)
+
+	host := n.hostNetns()
+	if host != 4026531992 {
+		t.Fatalf("host netns = %v", host)
+	}
+	blue, err := fileInode("testdata/netns/var/run/netns/blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := n.listNetns(host, time.Unix(1600000000, 0))
+	want := map[uint64]Netns{
+		4026532200: {Inode: 4026532200, Pid: 100, Path: n.fs.Proc("100", "ns", "net")},
+		4026532300: {Inode: 4026532300, Pid: 200, Path: n.fs.Proc("200", "ns", "net")},
+		blue:       {Inode: blue, Name: "blue", Path: n.fs.Path("var", "run", "netns", "blue")},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("got %+v", list)
+	}
+	for _, ns := range list {
+		if ns != want[ns.Inode] {
+			t.Errorf("got %+v, want %+v", ns, want[ns.Inode])
+		}
+	}
+}
+
+func TestCollectNetns(t *testing.T) {
+	n, now := newNetnsNetwork(t)
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(10 * time.Second)
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	snap := n.Current()
+
+	//blue没有进程, 回放时无法setns, 被跳过
+	wantKeys := []string{"eth0", "eth1", "net:[4026532200]/eth0", "net:[4026532300]/eth0"}
+	if len(snap.IfiNames) != len(wantKeys) {
+		t.Fatalf("IfiNames = %v, want %v", snap.IfiNames, wantKeys)
+	}
+	for i, key := range wantKeys {
+		if snap.IfiNames[i] != key {
+			t.Fatalf("IfiNames = %v, want %v", snap.IfiNames, wantKeys)
+		}
+	}
+
+	c := snap.IfiMap["net:[4026532200]/eth0"]
+	if c.Name != "eth0" || c.Netns != "net:[4026532200]" || c.NetnsInode != 4026532200 || c.Ip != "172.17.0.2" || c.Index != 3 {
+		t.Fatalf("unexpected container ifi: %+v", c)
+	}
+	if host := snap.IfiMap["eth0"]; host.Netns != "" || host.NetnsInode != 4026531992 || host.Index != 2 {
+		t.Fatalf("unexpected host ifi: netns=%v inode=%v index=%v", host.Netns, host.NetnsInode, host.Index)
+	}
+
+	//ifindex和整机统计只包含本机命名空间
+	if ifi, err := snap.IfiByIfIndex(3); err != nil || ifi.Key() != "eth1" {
+		t.Fatalf("IfiByIfIndex(3) = %v, %v, want host eth1", ifi, err)
+	}
+	if ifi, err := snap.Lookup("172.17.0.3"); err != nil || ifi.Key() != "net:[4026532300]/eth0" {
+		t.Fatalf("Lookup by container ip = %v, %v", ifi, err)
+	}
+	if snap.OutRecvByteAvg != 0 || snap.InRecvByteAvg != 0 {
+		t.Fatalf("zone totals include container traffic: out=%v in=%v", snap.OutRecvByteAvg, snap.InRecvByteAvg)
+	}
+
+	found := false
+	for _, s := range snap.Samples() {
+		if s.Name == "net_iface_recv_pkg_avg" && s.Labels["netns"] == "net:[4026532300]" {
+			found = s.Labels["iface"] == "eth0"
+		}
+	}
+	if !found {
+		t.Fatal("missing sample labelled with netns")
+	}
+
+	if got := n.EthModelFunc(""); got != "eth0|203.0.113.10|0$eth1|10.0.0.5|0$" {
+		t.Fatalf("EthModelFunc = %q", got)
+	}
+}
+
+func TestNetnsCache(t *testing.T) {
+	n, now := newNetnsNetwork(t)
+	calls := make(map[uint64]int)
+	resolve := n.netnsResolver
+	WithNetnsResolver(func(ns Netns) (Resolver, error) {
+		calls[ns.Inode]++
+		return resolve(ns)
+	})(n)
+
+	for i := 0; i < 3; i++ {
+		if err := n.Collect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		*now = now.Add(10 * time.Second)
+	}
+	if calls[4026532200] != 1 || calls[4026532300] != 1 {
+		t.Fatalf("unchanged namespaces should be resolved once, got %v", calls)
+	}
+
+	//网卡变化时重新查询
+	n.netnsLinks[4026532200].names = []string{"lo"}
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls[4026532200] != 2 || calls[4026532300] != 1 {
+		t.Fatalf("changed namespace should be resolved again, got %v", calls)
+	}
+
+	//缓存的pid已不在原命名空间时完整扫描
+	n.netnsPids[100] = 4026532999
+	n.netnsPids[101] = 4026532999
+	found := false
+	for _, ns := range n.listNetns(n.hostNetns(), *now) {
+		found = found || ns.Inode == 4026532200 && ns.Pid == 100
+	}
+	if !found {
+		t.Fatalf("stale pid cache not rescanned: %v", n.netnsPids)
+	}
+	if n.netnsPids[100] != 4026532200 {
+		t.Fatalf("pid cache not refreshed: %v", n.netnsPids)
+	}
+
+	//超过netnsRefresh后重新查询, 消失的命名空间被清除
+	n.netnsLinks[4026532999] = &netnsLinks{}
+	*now = now.Add(netnsRefresh)
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls[4026532200] != 3 || calls[4026532300] != 2 {
+		t.Fatalf("cache should expire after netnsRefresh, got %v", calls)
+	}
+	if _, exists := n.netnsLinks[4026532999]; exists {
+		t.Fatal("cache of vanished namespace not evicted")
+	}
+}
+
+func TestCollectNetnsDisabled(t *testing.T) {
+	n, _ := newFixtureNetwork(t, "testdata/netns")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Current().IfiNames) != 2 {
+		t.Fatalf("IfiNames = %v", n.Current().IfiNames)
+	}
+}
+
+func TestWithNetnsLive(t *testing.T) {
+	fs := procfs.NewFS(procfs.DefaultRoot)
+	if _, err := os.Readlink(fs.Proc("self", "ns", "net")); err != nil {
+		t.Skip("no /proc/self/ns/net")
+	}
+	//切换到自己所在的命名空间总是可以的, 验证切换和切回
+	err := withNetns(fs.Proc("self", "ns", "net"), func() error {
+		_, err := os.Stat("/proc/thread-self/net/dev")
+		return err
+	})
+	if err != nil {
+		t.Skipf("setns not permitted: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package net
+
+import (
+	"github.com/enoch300/collectd/procfs"
+)
+
+type Option func(n *NetWork)
+
+// WithRoot 指定/proc和/sys所在的根目录
+func WithRoot(root string) Option {
+	return func(n *NetWork) {
+		n.fs = procfs.NewFS(root)
+	}
+}
+
+// WithResolver 指定网络接口查询方式
+func WithResolver(r Resolver) Option {
+	return func(n *NetWork) {
+		n.resolver = r
+	}
+}
+
+// WithShaper 指定tc限速配置的获取方式, 默认在本机上通过netlink读取
+func WithShaper(s ShaperSource) Option {
+	return func(n *NetWork) {
+		n.shaper = s
+	}
+}
+
+// WithPlateau 开启流量平台检测, 默认不检测: 连续window个周期流量(Mb/s)不低于min,
+// 且最大最小值与均值的相对偏差不超过tolerance时认为被限速, 如WithPlateau(30, 0.05, 10);
+// 平稳的大流量传输也会被判为限速, 只应在已知有云厂商限速、速率未知的网卡上开启; window为0时不检测
+func WithPlateau(window int, tolerance, min float64) Option {
+	return func(n *NetWork) {
+		n.plateau = plateauConfig{window: window, tolerance: tolerance, min: min}
+	}
+}
+
+// WithSpeedOverride 为匹配pattern的网卡指定速率(Mb/s), 匹配规则同IgnoreEth;
+// 用于速率未知(-1)的虚拟网卡或速率与实际带宽不符的网卡, 多次指定时先指定的优先
+func WithSpeedOverride(pattern string, speed float64) Option {
+	return func(n *NetWork) {
+		n.speedOverrides = append(n.speedOverrides, speedOverride{pattern: namePatterns{pattern}, speed: speed})
+	}
+}
+
+// WithNetns 同时采集/var/run/netns和/proc/*/ns/net中的其他网络命名空间,
+// 需要读取其他进程的/proc和setns的权限
+func WithNetns() Option {
+	return func(n *NetWork) {
+		n.netns = true
+	}
+}
+
+// WithNetnsResolver 指定命名空间内网络接口的查询方式, 默认在本机上setns后查询
+func WithNetnsResolver(f func(ns Netns) (Resolver, error)) Option {
+	return func(n *NetWork) {
+		n.netnsResolver = f
+	}
+}
+
+// WithContainers 把本机一侧的veth归属到对端所在的容器, 容器ID取自容器内进程的cgroup,
+// 容器名由r查询, 例如NewDockerResolver(""); 需要读取其他进程/proc的权限
+func WithContainers(r ContainerResolver) Option {
+	return func(n *NetWork) {
+		n.containers = r
+	}
+}
+
+// WithNetlinkStats 本机命名空间的网卡计数、链路属性和地址通过一次netlink dump(IFLA_STATS64)读取,
+// 代替/proc/net/dev的解析(默认的SystemResolver也是一次dump读取地址); 只在读取本机(未指定WithRoot)时生效,
+// 同时指定WithResolver时网络接口和地址仍以该resolver为准, netlink只提供计数和链路属性
+func WithNetlinkStats() Option {
+	return func(n *NetWork) {
+		n.netlinkStats = true
+	}
+}
+
+// WithQueues 采集网卡每个收发队列的包数、字节数和中断(见QueueStat), 并计算队列不均衡度;
+// 队列来自sysfs的queues目录, 计数来自驱动统计, 中断来自/proc/interrupts
+func WithQueues() Option {
+	return func(n *NetWork) {
+		n.queues = true
+	}
+}
+
+// WithDriverCounters 采集网卡驱动统计(等同ethtool -S)和归一化计数(见DriverCounter);
+// include为保留在Ifi.DriverStats中的统计项, 匹配规则同IgnoreEth, 为空时保留全部, 归一化计数不受include影响
+func WithDriverCounters(include ...string) Option {
+	return func(n *NetWork) {
+		n.driverCounters = true
+		n.driverInclude = namePatterns(include)
+	}
+}
+
+// WithDriverStats 指定驱动统计的来源, 默认读取本机时通过ethtool ioctl(ETHTOOL_GSTATS)查询
+func WithDriverStats(src DriverStatsSource) Option {
+	return func(n *NetWork) {
+		n.driverStats = src
+	}
+}
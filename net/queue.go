@@ -0,0 +1,338 @@
+package net
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	QueueRecv = "rx"
+	QueueSend = "tx"
+)
+
+// QueueStat 网卡一个收发队列的计数和中断
+type QueueStat struct {
+	Dir   string `json:"dir"`   //rx/tx
+	Index int    `json:"index"` //队列编号
+
+	Packets uint64  `json:"packets"`  //驱动统计的包数, 驱动不提供时为0
+	Bytes   uint64  `json:"bytes"`    //驱动统计的字节数
+	PkgAvg  float64 `json:"pkg_avg"`  //一个周期平均每秒包数
+	ByteAvg float64 `json:"byte_avg"` //一个周期平均每秒字节数
+
+	IRQ      int     `json:"irq"`       //队列的中断号, 0表示未找到
+	IRQCPU   int     `json:"irq_cpu"`   //累计处理该中断最多的CPU, -1表示未知
+	IRQCount uint64  `json:"irq_count"` //所有CPU上的中断累计次数
+	IRQAvg   float64 `json:"irq_avg"`   //一个周期平均每秒中断次数
+
+	irqCounts []uint64 //每个CPU上的中断累计次数, 内核中是32位计数, 按CPU分别计算增量
+}
+
+type queueKey struct {
+	dir   string
+	index int
+}
+
+// queueStatPatterns 常见驱动的每队列统计项名称, 子匹配依次为方向、队列编号和计数类型
+var queueStatPatterns = []struct {
+	re               *regexp.Regexp
+	dir, index, kind int
+	packets, bytes   string
+}{
+	//virtio_net/ixgbe/igb/ice: rx_queue_0_packets
+	{re: regexp.MustCompile(`^(rx|tx)_queue_(\d+)_(packets|bytes)$`), dir: 1, index: 2, kind: 3, packets: "packets", bytes: "bytes"},
+	//i40e: rx-0.packets
+	{re: regexp.MustCompile(`^(rx|tx)-(\d+)\.(packets|bytes)$`), dir: 1, index: 2, kind: 3, packets: "packets", bytes: "bytes"},
+	//mlx5: rx0_packets
+	{re: regexp.MustCompile(`^(rx|tx)(\d+)_(packets|bytes)$`), dir: 1, index: 2, kind: 3, packets: "packets", bytes: "bytes"},
+	//ena: queue_0_rx_cnt
+	{re: regexp.MustCompile(`^queue_(\d+)_(rx|tx)_(cnt|bytes)$`), dir: 2, index: 1, kind: 3, packets: "cnt", bytes: "bytes"},
+}
+
+// parseQueueStat 识别驱动统计项中的每队列包数和字节数
+func parseQueueStat(name string) (key queueKey, packets bool, ok bool) {
+	for _, p := range queueStatPatterns {
+		m := p.re.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		index, err := strconv.Atoi(m[p.index])
+		if err != nil {
+			return queueKey{}, false, false
+		}
+		return queueKey{dir: m[p.dir], index: index}, m[p.kind] == p.packets, true
+	}
+	return queueKey{}, false, false
+}
+
+// irqStat /proc/interrupts中的一个中断
+type irqStat struct {
+	IRQ    int
+	Counts []uint64 //每个CPU上的累计次数
+	Action string   //中断处理程序名, 如eth0-TxRx-0、virtio0-input.0
+}
+
+// parseInterrupts 解析/proc/interrupts, 只保留数字编号的中断
+func parseInterrupts(r io.Reader) ([]irqStat, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !scanner.Scan() {
+		return nil, scanner.Err()
+	}
+	cpus := len(strings.Fields(scanner.Text()))
+
+	var irqs []irqStat
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < cpus+2 {
+			continue
+		}
+		irq, err := strconv.Atoi(strings.TrimSuffix(fields[0], ":"))
+		if err != nil {
+			continue
+		}
+		stat := irqStat{IRQ: irq, Counts: make([]uint64, cpus), Action: fields[len(fields)-1]}
+		for i := 0; i < cpus; i++ {
+			stat.Counts[i], _ = strconv.ParseUint(fields[1+i], 10, 64)
+		}
+		irqs = append(irqs, stat)
+	}
+	return irqs, scanner.Err()
+}
+
+// total 所有CPU上的累计次数和处理最多的CPU
+func (s *irqStat) total() (uint64, int) {
+	var sum, max uint64
+	cpu := -1
+	for i, c := range s.Counts {
+		sum += c
+		if c > max {
+			max, cpu = c, i
+		}
+	}
+	return sum, cpu
+}
+
+// queueIRQMarkers 队列中断名中的标记, 不含这些标记的中断(如mlx5_async0、virtio1-config)是控制或异步中断
+var queueIRQMarkers = []string{"txrx", "rx", "tx", "input", "output", "comp"}
+
+// matchQueueIRQ 判断中断是否属于网卡的队列, 中断名中含网卡名或设备名(PCI地址、virtioN)且含队列标记,
+// 去掉该名称后的最后一段数字为队列编号; 返回队列方向, 收发合一的中断两个方向都返回
+func matchQueueIRQ(action string, tokens ...string) ([]string, int, bool) {
+	if !hasQueueMarker(action) {
+		return nil, 0, false
+	}
+	for _, token := range tokens {
+		i := indexToken(action, token)
+		if i < 0 {
+			continue
+		}
+		rest := action[:i] + " " + action[i+len(token):]
+		index, ok := lastNumber(rest)
+		if !ok {
+			return nil, 0, false
+		}
+
+		lower := strings.ToLower(action[i+len(token):])
+		rx := strings.Contains(lower, "rx") || strings.Contains(lower, "input")
+		tx := strings.Contains(lower, "tx") || strings.Contains(lower, "output")
+		switch {
+		case rx && !tx:
+			return []string{QueueRecv}, index, true
+		case tx && !rx:
+			return []string{QueueSend}, index, true
+		}
+		return []string{QueueRecv, QueueSend}, index, true
+	}
+	return nil, 0, false
+}
+
+func hasQueueMarker(action string) bool {
+	lower := strings.ToLower(action)
+	for _, marker := range queueIRQMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexToken 查找不是更长名称一部分的token, 例如eth1不匹配eth10-TxRx-0
+func indexToken(s, token string) int {
+	if token == "" {
+		return -1
+	}
+	for start := 0; ; {
+		i := strings.Index(s[start:], token)
+		if i < 0 {
+			return -1
+		}
+		i += start
+		end := i + len(token)
+		if end == len(s) || !isAlnum(s[end]) {
+			return i
+		}
+		start = i + 1
+	}
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func lastNumber(s string) (int, bool) {
+	end := len(s)
+	for end > 0 && (s[end-1] < '0' || s[end-1] > '9') {
+		end--
+	}
+	start := end
+	for start > 0 && s[start-1] >= '0' && s[start-1] <= '9' {
+		start--
+	}
+	if start == end {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[start:end])
+	return n, err == nil
+}
+
+// readInterrupts 读取/proc/interrupts, 失败时不做中断关联
+func (n *NetWork) readInterrupts() []irqStat {
+	f, err := n.fs.Open(n.fs.Proc("interrupts"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	irqs, _ := parseInterrupts(f)
+	return irqs
+}
+
+// readQueues 汇总网卡的队列: 队列列表来自/sys/class/net/<name>/queues, 计数来自驱动统计stats,
+// 中断来自/proc/interrupts; prev为上次的队列, interval为距上次采集的秒数
+func (n *NetWork) readQueues(name, busInfo string, stats []DriverStat, prev []QueueStat, interval float64, irqs []irqStat) []QueueStat {
+	byKey := make(map[queueKey]*QueueStat)
+	queue := func(key queueKey) *QueueStat {
+		q, exists := byKey[key]
+		if !exists {
+			q = &QueueStat{Dir: key.dir, Index: key.index, IRQCPU: -1}
+			byKey[key] = q
+		}
+		return q
+	}
+
+	if entries, err := os.ReadDir(n.fs.Sys("class", "net", name, "queues")); err == nil {
+		for _, e := range entries {
+			parts := strings.SplitN(e.Name(), "-", 2)
+			if len(parts) != 2 || (parts[0] != QueueRecv && parts[0] != QueueSend) {
+				continue
+			}
+			if i, err := strconv.Atoi(parts[1]); err == nil {
+				queue(queueKey{dir: parts[0], index: i})
+			}
+		}
+	}
+
+	for _, s := range stats {
+		key, packets, ok := parseQueueStat(s.Name)
+		if !ok {
+			continue
+		}
+		if packets {
+			queue(key).Packets = s.Value
+		} else {
+			queue(key).Bytes = s.Value
+		}
+	}
+
+	for i := range irqs {
+		dirs, index, ok := matchQueueIRQ(irqs[i].Action, name, busInfo)
+		if !ok {
+			continue
+		}
+		count, cpu := irqs[i].total()
+		for _, dir := range dirs {
+			if q, exists := byKey[queueKey{dir: dir, index: index}]; exists {
+				q.IRQ, q.IRQCount, q.IRQCPU, q.irqCounts = irqs[i].IRQ, count, cpu, irqs[i].Counts
+			}
+		}
+	}
+
+	queues := make([]QueueStat, 0, len(byKey))
+	for _, q := range byKey {
+		queues = append(queues, *q)
+	}
+	sort.Slice(queues, func(i, j int) bool {
+		if queues[i].Dir != queues[j].Dir {
+			return queues[i].Dir < queues[j].Dir
+		}
+		return queues[i].Index < queues[j].Index
+	})
+
+	if interval > 0 {
+		last := make(map[queueKey]QueueStat, len(prev))
+		for _, q := range prev {
+			last[queueKey{dir: q.Dir, index: q.Index}] = q
+		}
+		for i := range queues {
+			q := &queues[i]
+			p, exists := last[queueKey{dir: q.Dir, index: q.Index}]
+			if !exists {
+				continue
+			}
+			if d, ok := counterDelta(p.Packets, q.Packets, counter64); ok {
+				q.PkgAvg = float64(d) / interval
+			}
+			if d, ok := counterDelta(p.Bytes, q.Bytes, counter64); ok {
+				q.ByteAvg = float64(d) / interval
+			}
+			if p.IRQ == q.IRQ {
+				if d, ok := irqDelta(p.irqCounts, q.irqCounts); ok {
+					q.IRQAvg = float64(d) / interval
+				}
+			}
+		}
+	}
+	return queues
+}
+
+// irqDelta 各CPU上中断次数增量之和, CPU数变化时不可用
+func irqDelta(prev, cur []uint64) (uint64, bool) {
+	if len(prev) != len(cur) {
+		return 0, false
+	}
+	var sum uint64
+	for i := range cur {
+		d, ok := counterDelta(prev[i], cur[i], counter32)
+		if !ok {
+			return 0, false
+		}
+		sum += d
+	}
+	return sum, true
+}
+
+// queueImbalance 一个方向上各队列包速率的不均衡度: (max/mean-1)/(k-1),
+// 0表示完全均衡, 1表示全部流量集中在一个队列; 队列少于2个或没有流量时为0
+func queueImbalance(queues []QueueStat, dir string) float64 {
+	var k int
+	var sum, max float64
+	for _, q := range queues {
+		if q.Dir != dir {
+			continue
+		}
+		k++
+		sum += q.PkgAvg
+		if q.PkgAvg > max {
+			max = q.PkgAvg
+		}
+	}
+	if k < 2 || sum == 0 {
+		return 0
+	}
+	return (max/(sum/float64(k)) - 1) / float64(k-1)
+}
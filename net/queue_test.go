@@ -0,0 +1,224 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQueueStat(t *testing.T) {
+	cases := []struct {
+		name    string
+		key     queueKey
+		packets bool
+		ok      bool
+	}{
+		{"rx_queue_3_packets", queueKey{QueueRecv, 3}, true, true},
+		{"tx_queue_0_bytes", queueKey{QueueSend, 0}, false, true},
+		{"rx-12.packets", queueKey{QueueRecv, 12}, true, true},
+		{"tx1_bytes", queueKey{QueueSend, 1}, false, true},
+		{"queue_2_rx_cnt", queueKey{QueueRecv, 2}, true, true},
+		{"queue_2_tx_bytes", queueKey{QueueSend, 2}, false, true},
+		{"rx_packets", queueKey{}, false, false},
+		{"rx_queue_0_drops", queueKey{}, false, false},
+	}
+	for _, c := range cases {
+		key, packets, ok := parseQueueStat(c.name)
+		if ok != c.ok || key != c.key || packets != c.packets {
+			t.Errorf("parseQueueStat(%q) = %v %v %v", c.name, key, packets, ok)
+		}
+	}
+}
+
+const testInterrupts = `           CPU0       CPU1       CPU2       CPU3
+  0:         20          0          0          0   IO-APIC   2-edge      timer
+ 24:          0          0          0          0   PCI-MSI 524288-edge      eth0
+ 25:       1000          0          0          0   PCI-MSI 524289-edge      eth0-TxRx-0
+ 26:          0       1000         10          0   PCI-MSI 524290-edge      eth0-TxRx-1
+ 27:          0          0       1000          0   PCI-MSI 524291-edge      eth0-TxRx-2
+ 28:          0          0          0       1000   PCI-MSI 524292-edge      eth0-TxRx-3
+ 29:        500          0          0          0   PCI-MSI 524293-edge      eth10-TxRx-0
+ 30:          0          0          0          0   PCI-MSIX-0000:00:04.0   0-edge      virtio1-config
+ 31:          0        300          0          0   PCI-MSIX-0000:00:04.0   1-edge      virtio1-input.0
+ 32:          0          0        200          0   PCI-MSIX-0000:00:04.0   2-edge      virtio1-output.0
+NMI:          0          0          0          0   Non-maskable interrupts
+`
+
+func TestParseInterrupts(t *testing.T) {
+	irqs, err := parseInterrupts(strings.NewReader(testInterrupts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(irqs) != 10 {
+		t.Fatalf("got %d irqs", len(irqs))
+	}
+	irq := irqs[3]
+	if irq.IRQ != 26 || irq.Action != "eth0-TxRx-1" || len(irq.Counts) != 4 {
+		t.Fatalf("unexpected irq %+v", irq)
+	}
+	if total, cpu := irq.total(); total != 1010 || cpu != 1 {
+		t.Fatalf("total = %v cpu = %v", total, cpu)
+	}
+}
+
+func TestMatchQueueIRQ(t *testing.T) {
+	cases := []struct {
+		action string
+		dirs   []string
+		index  int
+		ok     bool
+	}{
+		{"eth0-TxRx-3", []string{QueueRecv, QueueSend}, 3, true},
+		{"eth0-rx-2", []string{QueueRecv}, 2, true},
+		{"eth0-tx-1", []string{QueueSend}, 1, true},
+		{"mlx5_comp5@pci:0000:00:03.0", []string{QueueRecv, QueueSend}, 5, true},
+		{"mlx5_async0@pci:0000:00:03.0", nil, 0, false},
+		{"virtio1-input.0", []string{QueueRecv}, 0, true},
+		{"virtio1-output.0", []string{QueueSend}, 0, true},
+		{"virtio1-config", nil, 0, false},
+		{"eth0", nil, 0, false},
+		{"eth10-TxRx-0", nil, 0, false},
+		{"virtio10-input.0", nil, 0, false},
+	}
+	for _, c := range cases {
+		busInfo := "virtio1"
+		if strings.Contains(c.action, "pci:") {
+			busInfo = "0000:00:03.0"
+		}
+		dirs, index, ok := matchQueueIRQ(c.action, "eth0", busInfo)
+		if ok != c.ok || index != c.index || fmt.Sprint(dirs) != fmt.Sprint(c.dirs) {
+			t.Errorf("matchQueueIRQ(%q) = %v %v %v", c.action, dirs, index, ok)
+		}
+	}
+}
+
+func TestQueueImbalance(t *testing.T) {
+	queues := func(avgs ...float64) []QueueStat {
+		var qs []QueueStat
+		for i, avg := range avgs {
+			qs = append(qs, QueueStat{Dir: QueueRecv, Index: i, PkgAvg: avg})
+		}
+		return qs
+	}
+	cases := []struct {
+		queues []QueueStat
+		want   float64
+	}{
+		{queues(100, 100, 100, 100), 0},
+		{queues(400, 0, 0, 0), 1},
+		{queues(200, 100, 100, 0), 1.0 / 3},
+		{queues(100), 0},
+		{queues(0, 0), 0},
+	}
+	for _, c := range cases {
+		if got := queueImbalance(c.queues, QueueRecv); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("queueImbalance(%+v) = %v, want %v", c.queues, got, c.want)
+		}
+		if got := queueImbalance(c.queues, QueueSend); got != 0 {
+			t.Errorf("send imbalance = %v", got)
+		}
+	}
+}
+
+func writeQueueStats(stats StaticDriverStats, name string, packets ...uint64) {
+	stats[name] = nil
+	for i, p := range packets {
+		stats[name] = append(stats[name],
+			DriverStat{Name: fmt.Sprintf("rx_queue_%d_packets", i), Value: p},
+			DriverStat{Name: fmt.Sprintf("rx_queue_%d_bytes", i), Value: p * 100},
+		)
+	}
+}
+
+func TestCollectQueues(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 4; i++ {
+		writeSysFile(t, root, "eth0", fmt.Sprintf("queues/rx-%d/rps_cpus", i), "0")
+		writeSysFile(t, root, "eth0", fmt.Sprintf("queues/tx-%d/xps_cpus", i), "0")
+	}
+	writeSysFile(t, root, "eth1", "queues/rx-0/rps_cpus", "0")
+	writeSysFile(t, root, "eth1", "queues/tx-0/xps_cpus", "0")
+	if err := os.Symlink("../../../devices/pci0000:00/0000:00:04.0/virtio1", filepath.Join(root, "sys", "class", "net", "eth1", "device")); err != nil {
+		t.Fatal(err)
+	}
+	writeTopologyDev(t, root, map[string]uint64{})
+	//rx-0/tx-0的中断在CPU0上的32位计数回绕
+	writeInterrupts := func(count string) {
+		interrupts := strings.Replace(testInterrupts, " 25:       1000", " 25: "+count, 1)
+		if err := os.WriteFile(filepath.Join(root, "proc", "interrupts"), []byte(interrupts), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeInterrupts("4294967000")
+
+	r := StaticResolver{}
+	for i, name := range []string{"eth0", "eth1"} {
+		r[name] = &Link{Index: i + 2, Name: name, Addrs: []net.Addr{mustCIDR(t, fmt.Sprintf("203.0.113.%d/24", i+10))}}
+	}
+	stats := StaticDriverStats{}
+	writeQueueStats(stats, "eth0", 0, 0, 0, 0)
+
+	now := time.Unix(1600000000, 0)
+	n := NewNetwork([]string{}, []string{"lo"}, []string{}, []string{},
+		WithRoot(root), WithResolver(r), WithQueues(), WithDriverStats(stats))
+	n.now = func() time.Time { return now }
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	writeQueueStats(stats, "eth0", 4000, 0, 0, 0)
+	writeInterrupts("200")
+	now = now.Add(10 * time.Second)
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s := n.Current()
+
+	eth0 := s.IfiMap["eth0"]
+	if len(eth0.Queues) != 8 {
+		t.Fatalf("got queues %+v", eth0.Queues)
+	}
+	rx0 := eth0.Queues[0]
+	if len(rx0.irqCounts) != 4 {
+		t.Fatalf("rx-0 irq counts = %v", rx0.irqCounts)
+	}
+	rx0.irqCounts = nil
+	want := QueueStat{Dir: QueueRecv, Index: 0, Packets: 4000, Bytes: 400000, PkgAvg: 400, ByteAvg: 40000, IRQ: 25, IRQCPU: 0, IRQCount: 200, IRQAvg: 49.6}
+	if !reflect.DeepEqual(rx0, want) {
+		t.Fatalf("rx-0 = %+v, want %+v", rx0, want)
+	}
+	if tx1 := eth0.Queues[5]; tx1.Dir != QueueSend || tx1.Index != 1 || tx1.IRQ != 26 || tx1.IRQCPU != 1 {
+		t.Fatalf("tx-1 = %+v", tx1)
+	}
+	if eth0.RecvQueueImbalance != 1 || eth0.SendQueueImbalance != 0 {
+		t.Fatalf("imbalance recv=%v send=%v", eth0.RecvQueueImbalance, eth0.SendQueueImbalance)
+	}
+
+	//virtio网卡的中断以设备名命名, 且没有驱动统计
+	eth1 := s.IfiMap["eth1"]
+	if len(eth1.Queues) != 2 || eth1.Queues[0].IRQ != 31 || eth1.Queues[0].IRQCPU != 1 || eth1.Queues[1].IRQ != 32 {
+		t.Fatalf("eth1 queues = %+v", eth1.Queues)
+	}
+
+	var queueSamples int
+	for _, sample := range s.Samples() {
+		if sample.Name == "net_queue_pkg_avg" && sample.Labels["iface"] == "eth0" && sample.Labels["dir"] == "rx" && sample.Labels["queue"] == "0" {
+			if sample.Value != 400 {
+				t.Errorf("net_queue_pkg_avg = %v", sample.Value)
+			}
+		}
+		if strings.HasPrefix(sample.Name, "net_queue_") {
+			queueSamples++
+		}
+	}
+	if queueSamples != 10*len(queueMetrics) {
+		t.Fatalf("got %d queue samples", queueSamples)
+	}
+}
@@ -0,0 +1,118 @@
+package net
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var ErrLinkNotFound = errors.New("link not found")
+
+// Link 网络接口及其地址
+type Link struct {
+	Index int        //内核ifindex
+	Name  string     //网卡名
+	Addrs []net.Addr //网卡地址
+
+	attrs linkAttrs //netlink返回的链路属性
+}
+
+// Resolver 根据网卡名查询网络接口
+type Resolver interface {
+	LinkByName(name string) (*Link, error)
+}
+
+// linkLister 可选接口, 一次返回所有网络接口; 每次采集调用一次, 代替逐个网卡调用LinkByName
+type linkLister interface {
+	Links() (StaticResolver, error)
+}
+
+// SystemResolver 使用本机网络接口
+type SystemResolver struct{}
+
+// Links 一次读取本机所有网络接口及其地址; net.InterfaceByName和Addrs每次调用都会读取全部网卡和地址,
+// 网卡很多时逐个查询是O(n²)
+func (SystemResolver) Links() (StaticResolver, error) {
+	return systemLinks()
+}
+
+func (SystemResolver) LinkByName(name string) (*Link, error) {
+	netIfi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := netIfi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Link{Index: netIfi.Index, Name: netIfi.Name, Addrs: addrs}, nil
+}
+
+// StaticResolver 固定的网络接口表, 用于回放现场数据
+type StaticResolver map[string]*Link
+
+// Links 返回整个接口表
+func (r StaticResolver) Links() (StaticResolver, error) {
+	return r, nil
+}
+
+func (r StaticResolver) LinkByName(name string) (*Link, error) {
+	link, exists := r[name]
+	if !exists {
+		return nil, ErrLinkNotFound
+	}
+	return link, nil
+}
+
+// ParseIPAddr 解析`ip -o addr show`的输出
+func ParseIPAddr(reader io.Reader) (StaticResolver, error) {
+	r := StaticResolver{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		//2: eth0    inet 10.0.0.5/24 brd 10.0.0.255 scope global eth0\       valid_lft forever preferred_lft forever
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+
+		index, err := strconv.Atoi(strings.TrimSuffix(fields[0], ":"))
+		if err != nil {
+			continue
+		}
+
+		name := strings.Split(fields[1], "@")[0]
+		link, exists := r[name]
+		if !exists {
+			link = &Link{Index: index, Name: name}
+			r[name] = link
+		}
+
+		if fields[2] != "inet" && fields[2] != "inet6" {
+			continue
+		}
+
+		ip, ipNet, err := net.ParseCIDR(fields[3])
+		if err != nil {
+			continue
+		}
+		ipNet.IP = ip
+		link.Addrs = append(link.Addrs, ipNet)
+	}
+	return r, scanner.Err()
+}
+
+// LoadIPAddr 从文件加载`ip -o addr show`的输出
+func LoadIPAddr(path string) (StaticResolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseIPAddr(f)
+}
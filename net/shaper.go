@@ -0,0 +1,222 @@
+package net
+
+import (
+	"math"
+)
+
+const (
+	LimitSourceTBF     = "tbf"
+	LimitSourceHTB     = "htb"
+	LimitSourceCake    = "cake"
+	LimitSourcePolice  = "police"
+	LimitSourcePlateau = "plateau" //流量长时间停在低于网卡速率的平台上, 通常是云厂商限速
+
+	plateauSpeedRatio = 0.95 //接近网卡速率时是跑满网卡, 不算限速
+)
+
+// Shaper 网卡的限速配置, 单位Mb/s, 0表示未检测到限速
+type Shaper struct {
+	RecvLimit  float64 //接收方向限速
+	SendLimit  float64 //发送方向限速
+	RecvSource string  //接收方向限速来源 tbf/htb/cake/police/plateau
+	SendSource string  //发送方向限速来源
+}
+
+// setRecv 记录接收方向限速, 多个限速时取最严格的
+func (s *Shaper) setRecv(rate uint64, source string) {
+	if mbps := bytesToMbps(rate); mbps > 0 && (s.RecvLimit == 0 || mbps < s.RecvLimit) {
+		s.RecvLimit, s.RecvSource = mbps, source
+	}
+}
+
+// setSend 记录发送方向限速, 多个限速时取最严格的
+func (s *Shaper) setSend(rate uint64, source string) {
+	if mbps := bytesToMbps(rate); mbps > 0 && (s.SendLimit == 0 || mbps < s.SendLimit) {
+		s.SendLimit, s.SendSource = mbps, source
+	}
+}
+
+// bytesToMbps 字节/秒转换为Mb/s(10^6)
+func bytesToMbps(rate uint64) float64 {
+	return float64(rate) * 8 / 1e6
+}
+
+// ShaperSource 获取所有网卡的tc限速配置, 按ifindex索引
+type ShaperSource interface {
+	Shapers() (map[int]Shaper, error)
+}
+
+// StaticShaper 固定的限速配置, 用于测试和回放
+type StaticShaper map[int]Shaper
+
+func (s StaticShaper) Shapers() (map[int]Shaper, error) {
+	return s, nil
+}
+
+// tcDumper 读取tc配置
+type tcDumper interface {
+	qdiscs() ([]tcObject, error)
+	classes(ifindex int) ([]tcObject, error)
+	filters(ifindex int, parent uint32) ([]tcObject, error)
+}
+
+// tcShapers 根据qdisc/class/filter计算限速:
+// 根qdisc为tbf/cake或根htb class的ceil限制发送, ingress和clsact上的police分别限制接收和发送;
+// filter只在ingress、clsact和tbf/cake/htb下查询
+func tcShapers(d tcDumper) (map[int]Shaper, error) {
+	qdiscs, err := d.qdiscs()
+	if err != nil {
+		return nil, err
+	}
+
+	shapers := make(map[int]Shaper)
+	for _, q := range qdiscs {
+		s := shapers[q.ifindex]
+		switch {
+		case q.kind == "ingress":
+			s.setRecv(filtersPoliceRate(d, q.ifindex, q.handle), LimitSourcePolice)
+		case q.kind == "clsact":
+			s.setRecv(filtersPoliceRate(d, q.ifindex, q.handle&tcHMajMask|tcHMinIngr), LimitSourcePolice)
+			s.setSend(filtersPoliceRate(d, q.ifindex, q.handle&tcHMajMask|tcHMinEgr), LimitSourcePolice)
+		case q.parent == tcHRoot:
+			//只查询限速qdisc上的filter, 大量veth上默认的noqueue/fq_codel等不逐个dump
+			switch q.kind {
+			case "tbf":
+				s.setSend(qdiscRate(q), LimitSourceTBF)
+			case "cake":
+				s.setSend(qdiscRate(q), LimitSourceCake)
+			case "htb":
+				if classes, err := d.classes(q.ifindex); err == nil {
+					s.setSend(htbRootCeil(classes), LimitSourceHTB)
+				}
+			default:
+				continue
+			}
+			s.setSend(filtersPoliceRate(d, q.ifindex, q.handle), LimitSourcePolice)
+		}
+		if s.RecvLimit > 0 || s.SendLimit > 0 {
+			shapers[q.ifindex] = s
+		}
+	}
+	return shapers, nil
+}
+
+// htbRootCeil 所有根class的ceil之和
+func htbRootCeil(classes []tcObject) uint64 {
+	var sum uint64
+	for _, c := range classes {
+		if c.parent == tcHRoot {
+			sum += htbCeil(c)
+		}
+	}
+	return sum
+}
+
+// filtersPoliceRate 挂在parent上的所有filter中最严格的police限速
+func filtersPoliceRate(d tcDumper, ifindex int, parent uint32) uint64 {
+	filters, err := d.filters(ifindex, parent)
+	if err != nil {
+		return 0
+	}
+	var rate uint64
+	for _, f := range filters {
+		rate = minRate(rate, filterPoliceRate(f))
+	}
+	return rate
+}
+
+// plateauConfig 流量平台检测参数
+type plateauConfig struct {
+	window    int     //连续采集周期数, 0表示不检测
+	tolerance float64 //窗口内最大最小值与均值的相对偏差
+	min       float64 //低于该流量(Mb/s)不判断平台
+}
+
+// plateau 最近window个周期的流量(Mb/s)
+type plateau struct {
+	samples []float64
+	next    int
+	full    bool
+}
+
+// observe 记录一个周期的流量, 返回检测到的平台值, 0表示没有平台
+func (p *plateau) observe(v, speed float64, cfg plateauConfig) float64 {
+	if len(p.samples) != cfg.window {
+		*p = plateau{samples: make([]float64, cfg.window)}
+	}
+	p.samples[p.next] = v
+	p.next = (p.next + 1) % cfg.window
+	if p.next == 0 {
+		p.full = true
+	}
+	if !p.full {
+		return 0
+	}
+
+	min, max, sum := math.Inf(1), math.Inf(-1), 0.0
+	for _, s := range p.samples {
+		min = math.Min(min, s)
+		max = math.Max(max, s)
+		sum += s
+	}
+	mean := sum / float64(len(p.samples))
+	if mean < cfg.min || (max-min)/mean > cfg.tolerance {
+		return 0
+	}
+	//网卡速率未知时(多数云主机的virtio网卡)只根据平台判断
+	if speed > 0 && max >= speed*plateauSpeedRatio {
+		return 0
+	}
+	return max
+}
+
+// ifiPlateau 网卡收发两个方向的平台检测状态
+type ifiPlateau struct {
+	recv plateau
+	send plateau
+}
+
+// shapers 获取本周期的tc限速配置, 获取失败时按没有限速处理
+func (n *NetWork) shapers() map[int]Shaper {
+	if n.shaper == nil {
+		return nil
+	}
+	shapers, err := n.shaper.Shapers()
+	if err != nil {
+		return nil
+	}
+	return shapers
+}
+
+// applyLimit 设置网卡限速, tc限速优先, 没有tc限速的方向再按流量平台判断
+func (n *NetWork) applyLimit(ifi *Ifi, key string, s Shaper) {
+	if n.plateau.window > 0 {
+		p, exists := n.plateaus[key]
+		if !exists {
+			p = &ifiPlateau{}
+			n.plateaus[key] = p
+		}
+		if s.RecvLimit == 0 {
+			s.RecvLimit = p.recv.observe(bytesToMbps(uint64(ifi.RecvByteAvg)), ifi.Speed, n.plateau)
+			s.RecvSource = limitSource(s.RecvLimit, LimitSourcePlateau)
+		}
+		if s.SendLimit == 0 {
+			s.SendLimit = p.send.observe(bytesToMbps(uint64(ifi.SendByteAvg)), ifi.Speed, n.plateau)
+			s.SendSource = limitSource(s.SendLimit, LimitSourcePlateau)
+		}
+	}
+
+	ifi.RecvLimit, ifi.RecvLimitSource = s.RecvLimit, s.RecvSource
+	ifi.SendLimit, ifi.SendLimitSource = s.SendLimit, s.SendSource
+	ifi.BandwidthLimit = 0
+	if s.RecvLimit > 0 || s.SendLimit > 0 {
+		ifi.BandwidthLimit = 1
+	}
+}
+
+func limitSource(limit float64, source string) string {
+	if limit > 0 {
+		return source
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package net
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPlateau(t *testing.T) {
+	cfg := plateauConfig{window: 5, tolerance: 0.05, min: 10}
+
+	var p plateau
+	for i, v := range []float64{50, 99, 100, 98, 100} {
+		if got := p.observe(v, 1000, cfg); i < 4 && got != 0 {
+			t.Fatalf("sample %d: plateau %v before window is full", i, got)
+		}
+	}
+	//窗口内有50, 偏差太大
+	if got := p.observe(99, 1000, cfg); got != 100 {
+		t.Fatalf("plateau = %v, want 100", got)
+	}
+
+	//跑满网卡速率不算限速
+	p = plateau{}
+	for i := 0; i < cfg.window; i++ {
+		if got := p.observe(990, 1000, cfg); got != 0 {
+			t.Fatalf("plateau = %v at link speed", got)
+		}
+	}
+
+	//流量太小
+	p = plateau{}
+	for i := 0; i < cfg.window; i++ {
+		if got := p.observe(1, 0, cfg); got != 0 {
+			t.Fatalf("plateau = %v below min", got)
+		}
+	}
+}
+
+func TestCollectShaper(t *testing.T) {
+	n, _ := newFixtureNetwork(t, "testdata/host/t0")
+	WithShaper(StaticShaper{2: {SendLimit: 100, SendSource: LimitSourceTBF}})(n)
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	eth0 := n.Current().IfiMap["eth0"]
+	if eth0.BandwidthLimit != 1 || eth0.SendLimit != 100 || eth0.SendLimitSource != LimitSourceTBF || eth0.RecvLimit != 0 {
+		t.Fatalf("eth0 limit=%v send=%v source=%v recv=%v", eth0.BandwidthLimit, eth0.SendLimit, eth0.SendLimitSource, eth0.RecvLimit)
+	}
+	if got := n.GetIfiBandwidthLimitStatusByIp("203.0.113.10"); got != 1 {
+		t.Fatalf("GetIfiBandwidthLimitStatusByIp = %v, want 1", got)
+	}
+	if eth1 := n.Current().IfiMap["eth1"]; eth1.BandwidthLimit != 0 {
+		t.Fatalf("eth1 limit = %v", eth1.BandwidthLimit)
+	}
+}
+
+func TestPlateauOptIn(t *testing.T) {
+	//平稳的100Mb/s传输
+	steady := func(n *NetWork) *Ifi {
+		ifi := &Ifi{Name: "eth0", RecvByteAvg: 12.5e6}
+		for i := 0; i < 40; i++ {
+			n.applyLimit(ifi, "eth0", Shaper{})
+		}
+		return ifi
+	}
+
+	n := NewNetwork([]string{}, []string{"lo"}, []string{}, []string{}, WithRoot(t.TempDir()))
+	if ifi := steady(n); ifi.BandwidthLimit != 0 || len(n.plateaus) != 0 {
+		t.Fatalf("plateau detected without WithPlateau: %+v", ifi)
+	}
+
+	n = NewNetwork([]string{}, []string{"lo"}, []string{}, []string{}, WithRoot(t.TempDir()), WithPlateau(30, 0.05, 10))
+	if ifi := steady(n); ifi.RecvLimit != 100 || ifi.RecvLimitSource != LimitSourcePlateau {
+		t.Fatalf("recv limit = %v %v", ifi.RecvLimit, ifi.RecvLimitSource)
+	}
+}
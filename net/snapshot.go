@@ -0,0 +1,513 @@
+package net
+
+import (
+	"errors"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enoch300/collectd/collector"
+)
+
+var (
+	ErrInvalidIndex    = errors.New("invalid index")
+	ErrInvalidSelector = errors.New("invalid selector")
+	ErrIfiNotFound     = errors.New("key not found")
+)
+
+// Snapshot 一次采集的完整结果, 发布后不可修改, 可以在任意goroutine中读取
+type Snapshot struct {
+	Time     time.Time       //采集时间
+	IfiMap   map[string]*Ifi //Ifi.Key()到网卡的映射, 本机命名空间的网卡即为网卡名
+	IfiNames []string        //Ifi.Key(), 按名称排序, 不随采集顺序和网卡增减而变化
+
+	Containers   map[string]*ContainerStat //容器ID到容器流量的映射
+	ContainerIDs []string                  //容器ID, 排序
+
+	Topology []TopologyLink //本机命名空间网卡的上下层关系
+
+	byIndex map[int]*Ifi    //ifindex到网卡的映射
+	zone    map[*Ifi]bool   //本机命名空间参与整机统计的网卡是否为内网
+	byIP    map[string]*Ifi //所有地址到网卡的映射
+
+	//内网
+	InRecvByteAvg float64 //所有内网网络接口平均接收字节数
+	InSendByteAvg float64 //所有内网网络接口平均发送字节数
+
+	InRecvPkgAvg float64 //所有内网网络接口平均收包数
+	InSendPkgAvg float64 //所有内网网络接口平均发包数
+
+	InRecvErrPkgAvg float64 //所有内网网络接口平均收包错误数
+	InSendErrPkgAvg float64 //所有内网网络接口平均发包错误数
+
+	InRecvDropPkgAvg float64 //所有内网网络接口平均收包丢包数
+	InSendDropPkgAvg float64 //所有内网网络接口平均发包丢包数
+
+	InRecvDropPkgRate float64 //所有内网网络接口收包丢包率
+	InSendDropPkgRate float64 //所有内网网络接口发包丢包率
+
+	InRecvErrPkgRate float64 //所有内网网络接口收包错误率
+	InSendErrPkgRate float64 //所有内网网络接口发包错误率
+
+	//外网
+	OutRecvDropPkgAvg float64 //所有外网接口平均接收丢包数
+	OutSendDropPkgAvg float64 //所有外网接口平均发送丢包数
+
+	OutRecvErrPkgAvg float64 //所有外网接口平均接收错误数
+	OutSendErrPkgAvg float64 //所有外网接口平均发送错误数
+
+	OutRecvPkgAvg float64 //所有外网接口平均接收包数
+	OutSendPkgAvg float64 //所有外网接口平均发送包数
+
+	OutRecvByteAvg float64 //所有外网接口平均接收字节数
+	OutSendByteAvg float64 //所有外网接口平均发送字节数
+
+	OutRecvDropPkgRate float64 //所有外网接口平均接收丢包率
+	OutSendDropPkgRate float64 //所有外网接口平均发送丢包率
+
+	OutRecvErrPkgRate float64 //所有外网接口平均接收错误率
+	OutSendErrPkgRate float64 //所有外网接口平均发送错误率
+
+	//带宽使用率(%), 只统计速率已知的网卡
+	InRecvMaxUseRate  float64 //内网网卡接收带宽最大使用率
+	InRecvAvgUseRate  float64 //内网网卡接收带宽平均使用率
+	InSendMaxUseRate  float64 //内网网卡发送带宽最大使用率
+	InSendAvgUseRate  float64 //内网网卡发送带宽平均使用率
+	OutRecvMaxUseRate float64 //外网网卡接收带宽最大使用率
+	OutRecvAvgUseRate float64 //外网网卡接收带宽平均使用率
+	OutSendMaxUseRate float64 //外网网卡发送带宽最大使用率
+	OutSendAvgUseRate float64 //外网网卡发送带宽平均使用率
+
+	EthInMaxUseRate  float64 //所有网卡接收(入)带宽最大使用率
+	EthOutMaxUseRate float64 //所有网卡发送(出)带宽最大使用率
+
+	inUseRate  useRateStat
+	outUseRate useRateStat
+}
+
+// ContainerStat 一个容器所有veth的流量, 以容器为视角
+type ContainerStat struct {
+	Container
+	Ifaces []string //本机一侧的veth
+
+	RecvByteAvg    float64 //平均每秒接收字节数
+	SendByteAvg    float64 //平均每秒发送字节数
+	RecvPkgAvg     float64 //平均每秒收包数
+	SendPkgAvg     float64 //平均每秒发包数
+	RecvDropPkgAvg float64 //平均每秒收包丢包数
+	SendDropPkgAvg float64 //平均每秒发包丢包数
+}
+
+// addPeer 加入容器在本机一侧的veth, veth发送即容器接收
+func (c *ContainerStat) addPeer(ifi *Ifi) {
+	c.Ifaces = append(c.Ifaces, ifi.Name)
+	c.RecvByteAvg += ifi.SendByteAvg
+	c.SendByteAvg += ifi.RecvByteAvg
+	c.RecvPkgAvg += ifi.SendPkgAvg
+	c.SendPkgAvg += ifi.RecvPkgAvg
+	c.RecvDropPkgAvg += ifi.SendDropPkgAvg
+	c.SendDropPkgAvg += ifi.RecvDropPkgAvg
+}
+
+// newSnapshot size为预计的网卡数, 通常为上次采集的网卡数, 避免采集过程中扩容
+func newSnapshot(now time.Time, size int) *Snapshot {
+	return &Snapshot{
+		Time:         now,
+		IfiMap:       make(map[string]*Ifi, size),
+		IfiNames:     make([]string, 0, size),
+		Containers:   make(map[string]*ContainerStat),
+		ContainerIDs: []string{},
+		byIndex:      make(map[int]*Ifi, size),
+		byIP:         make(map[string]*Ifi, size),
+		zone:         make(map[*Ifi]bool, size),
+	}
+}
+
+// apply 复制快照并应用Watch收到的消息, 用于两次采集之间发布网卡状态、地址的变化和网卡的删除, 返回网卡的变化事件;
+// 网卡由快照中的副本复制, Counted等只在finish中计算的字段保持不变; 速率和整机统计保持不变, 没有变化时返回s本身
+func (s *Snapshot) apply(msgs []linkMessage, now time.Time) (*Snapshot, []Event) {
+	var cp *Snapshot
+	var events []Event
+	deleted := false
+	byIndex := s.byIndex
+	for _, msg := range msgs {
+		prev, exists := byIndex[msg.index]
+		if !exists {
+			continue
+		}
+		ifi := *prev
+		if msg.typ != rtmDelLink && !applyLinkMessage(&ifi, msg) {
+			continue
+		}
+
+		if cp == nil {
+			c := *s
+			cp = &c
+			cp.IfiMap = make(map[string]*Ifi, len(s.IfiMap))
+			for key, ifi := range s.IfiMap {
+				cp.IfiMap[key] = ifi
+			}
+			cp.byIndex = make(map[int]*Ifi, len(s.byIndex))
+			for index, ifi := range s.byIndex {
+				cp.byIndex[index] = ifi
+			}
+			byIndex = cp.byIndex
+		}
+		key := prev.Key()
+		if msg.typ == rtmDelLink {
+			delete(cp.IfiMap, key)
+			delete(cp.byIndex, msg.index)
+			deleted = true
+			continue
+		}
+		events = append(events, changeEvents(key, prev, &ifi, now)...)
+		cp.IfiMap[key] = &ifi
+		cp.byIndex[msg.index] = &ifi
+	}
+	if cp == nil {
+		return s, nil
+	}
+
+	if deleted {
+		names := make([]string, 0, len(cp.IfiMap))
+		for _, key := range s.IfiNames {
+			if _, exists := cp.IfiMap[key]; exists {
+				names = append(names, key)
+			}
+		}
+		cp.IfiNames = names
+	}
+	cp.byIndex = make(map[int]*Ifi, len(s.byIndex))
+	cp.byIP = make(map[string]*Ifi, len(s.byIP))
+	//与add相同, 按IP查找时本机命名空间优先
+	for _, host := range []bool{true, false} {
+		for _, key := range cp.IfiNames {
+			ifi := cp.IfiMap[key]
+			if (ifi.Netns == "") != host {
+				continue
+			}
+			for _, a := range ifi.Addrs {
+				if _, exists := cp.byIP[a.IP]; !exists {
+					cp.byIP[a.IP] = ifi
+				}
+			}
+			if host && ifi.Index > 0 {
+				cp.byIndex[ifi.Index] = ifi
+			}
+		}
+	}
+	return cp, events
+}
+
+// add 加入一个网卡, 只在发布前调用
+// 其他命名空间的网卡不参与整机统计和按ifindex查找, 按IP查找时本机命名空间优先;
+// 容器在本机一侧的veth计入容器流量, 不计入整机统计, 其流量已经经过网桥或物理网卡;
+// 整机统计在finish中计算, 因为需要知道承载网卡是否也被采集
+func (s *Snapshot) add(ifi *Ifi, in bool) {
+	key := ifi.Key()
+	s.IfiMap[key] = ifi
+	s.IfiNames = append(s.IfiNames, key)
+	for _, a := range ifi.Addrs {
+		if _, exists := s.byIP[a.IP]; !exists {
+			s.byIP[a.IP] = ifi
+		}
+	}
+	if ifi.Netns != "" {
+		return
+	}
+	if ifi.Index > 0 {
+		s.byIndex[ifi.Index] = ifi
+	}
+	if ifi.ContainerID != "" {
+		cs, exists := s.Containers[ifi.ContainerID]
+		if !exists {
+			cs = &ContainerStat{Container: Container{ID: ifi.ContainerID, Name: ifi.ContainerName, Runtime: ifi.ContainerRuntime}}
+			s.Containers[ifi.ContainerID] = cs
+			s.ContainerIDs = append(s.ContainerIDs, ifi.ContainerID)
+		}
+		cs.addPeer(ifi)
+		return
+	}
+	s.zone[ifi] = in
+}
+
+func (s *Snapshot) total(ifi *Ifi, in bool) {
+	if in {
+		//内网
+		s.InRecvByteAvg += ifi.RecvByteAvg
+		s.InSendByteAvg += ifi.SendByteAvg
+
+		s.InRecvPkgAvg += ifi.RecvPkgAvg
+		s.InSendPkgAvg += ifi.SendPkgAvg
+
+		s.InRecvErrPkgAvg += ifi.RecvErrPkgAvg
+		s.InSendErrPkgAvg += ifi.SendErrPkgAvg
+
+		s.InRecvDropPkgAvg += ifi.RecvDropPkgAvg
+		s.InSendDropPkgAvg += ifi.SendDropPkgAvg
+
+		s.InRecvErrPkgRate += ifi.RecvErrRate
+		s.InSendErrPkgRate += ifi.SendErrRate
+
+		s.InRecvDropPkgRate += ifi.RecvDropRate
+		s.InSendDropPkgRate += ifi.SendDropRate
+	} else {
+		//外网
+		s.OutRecvPkgAvg += ifi.RecvPkgAvg
+		s.OutSendPkgAvg += ifi.SendPkgAvg
+
+		s.OutRecvByteAvg += ifi.RecvByteAvg
+		s.OutSendByteAvg += ifi.SendByteAvg
+
+		s.OutSendErrPkgAvg += ifi.SendErrPkgAvg
+		s.OutSendDropPkgAvg += ifi.SendDropPkgAvg
+
+		s.OutRecvErrPkgAvg += ifi.RecvErrPkgAvg
+		s.OutRecvDropPkgAvg += ifi.RecvDropPkgAvg
+
+		s.OutRecvErrPkgRate += ifi.RecvErrRate
+		s.OutSendErrPkgRate += ifi.SendErrRate
+
+		s.OutRecvDropPkgRate += ifi.RecvDropRate
+		s.OutSendDropPkgRate += ifi.SendDropRate
+	}
+}
+
+// finish 排序网卡并计算整机统计和带宽使用率, 只在发布前调用;
+// 承载网卡也被采集的网卡(bond/team从属网卡、VLAN、网桥, 见covered)不计入整机流量以免重复统计,
+// 但仍参与带宽使用率, 以便发现单个从属网卡打满
+func (s *Snapshot) finish(prev []string) {
+	s.sortNames(prev)
+
+	for _, key := range s.IfiNames {
+		ifi := s.IfiMap[key]
+		in, exists := s.zone[ifi]
+		if !exists {
+			continue
+		}
+		if !s.covered(ifi) {
+			ifi.Counted = true
+			s.total(ifi, in)
+		}
+		if in {
+			s.inUseRate.add(ifi)
+		} else {
+			s.outUseRate.add(ifi)
+		}
+	}
+	s.zone = nil
+	s.Topology = s.topology()
+
+	s.InRecvMaxUseRate, s.InSendMaxUseRate = s.inUseRate.recvMax, s.inUseRate.sendMax
+	s.InRecvAvgUseRate, s.InSendAvgUseRate = s.inUseRate.avg()
+	s.OutRecvMaxUseRate, s.OutSendMaxUseRate = s.outUseRate.recvMax, s.outUseRate.sendMax
+	s.OutRecvAvgUseRate, s.OutSendAvgUseRate = s.outUseRate.avg()
+
+	s.EthInMaxUseRate = math.Max(s.InRecvMaxUseRate, s.OutRecvMaxUseRate)
+	s.EthOutMaxUseRate = math.Max(s.InSendMaxUseRate, s.OutSendMaxUseRate)
+}
+
+// sortNames 按名称排序网卡; 网卡与上次采集(prev)相同时直接沿用上次的顺序, 稳定状态下为O(n)
+func (s *Snapshot) sortNames(prev []string) {
+	same := len(prev) == len(s.IfiNames)
+	for i := 0; same && i < len(prev); i++ {
+		_, same = s.IfiMap[prev[i]]
+	}
+	if same {
+		copy(s.IfiNames, prev)
+	} else {
+		sort.Strings(s.IfiNames)
+	}
+	sort.Strings(s.ContainerIDs)
+}
+
+// GetIfiByIndex 按网卡在IfiNames中的位置查找网卡
+func (s *Snapshot) GetIfiByIndex(args string) (*Ifi, error) {
+	index, err := strconv.Atoi(args)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index > len(s.IfiNames)-1 {
+		return nil, ErrInvalidIndex
+	}
+	return s.IfiByName(s.IfiNames[index])
+}
+
+// IfiByName 按网卡名查找网卡
+func (s *Snapshot) IfiByName(name string) (*Ifi, error) {
+	ifi, exists := s.IfiMap[name]
+	if !exists {
+		return nil, ErrIfiNotFound
+	}
+	return ifi, nil
+}
+
+// IfiByIfIndex 按内核ifindex查找网卡
+func (s *Snapshot) IfiByIfIndex(index int) (*Ifi, error) {
+	ifi, exists := s.byIndex[index]
+	if !exists {
+		return nil, ErrIfiNotFound
+	}
+	return ifi, nil
+}
+
+// IfiByIP 按IP查找网卡
+func (s *Snapshot) IfiByIP(ip string) (*Ifi, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, ErrInvalidSelector
+	}
+	ifi, exists := s.byIP[parsed.String()]
+	if !exists {
+		return nil, ErrIfiNotFound
+	}
+	return ifi, nil
+}
+
+// Lookup 按选择器查找网卡:
+//
+//	name:eth0     按网卡名
+//	ifindex:2     按内核ifindex
+//	ip:10.0.0.1   按IP
+//	0             按IfiNames中的位置(兼容旧的GetIfiByIndex)
+//	10.0.0.1      按IP
+//	eth0          按网卡名
+func (s *Snapshot) Lookup(selector string) (*Ifi, error) {
+	selector = strings.TrimSpace(selector)
+	if selector == "" {
+		return nil, ErrInvalidSelector
+	}
+
+	if kind, value, ok := cutSelector(selector); ok {
+		switch kind {
+		case "name":
+			return s.IfiByName(value)
+		case "ifindex":
+			index, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, ErrInvalidSelector
+			}
+			return s.IfiByIfIndex(index)
+		case "ip":
+			return s.IfiByIP(value)
+		}
+	}
+
+	if _, err := strconv.Atoi(selector); err == nil {
+		return s.GetIfiByIndex(selector)
+	}
+	if net.ParseIP(selector) != nil {
+		return s.IfiByIP(selector)
+	}
+	return s.IfiByName(selector)
+}
+
+// cutSelector 拆分kind:value形式的选择器, IPv6地址不会被误拆
+func cutSelector(selector string) (kind, value string, ok bool) {
+	i := strings.Index(selector, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	kind = selector[:i]
+	switch kind {
+	case "name", "ifindex", "ip":
+		return kind, selector[i+1:], true
+	}
+	return "", "", false
+}
+
+// Samples 快照中的全部指标
+func (s *Snapshot) Samples() []collector.Sample {
+	samples := make([]collector.Sample, 0, len(zoneMetrics)*2+len(hostMetrics)+len(ifaceMetrics)*len(s.IfiNames)+len(containerMetrics)*len(s.ContainerIDs))
+	for _, m := range zoneMetrics {
+		samples = append(samples,
+			collector.Sample{Name: m.desc.Name, Labels: map[string]string{"zone": "in"}, Value: m.in(s)},
+			collector.Sample{Name: m.desc.Name, Labels: map[string]string{"zone": "out"}, Value: m.out(s)},
+		)
+	}
+	for _, m := range hostMetrics {
+		samples = append(samples, collector.Sample{Name: m.desc.Name, Value: m.value(s)})
+	}
+	for _, name := range s.IfiNames {
+		ifi, exists := s.IfiMap[name]
+		if !exists {
+			continue
+		}
+		for _, m := range ifaceMetrics {
+			if m.known != nil && !m.known(ifi) {
+				continue
+			}
+			samples = append(samples, collector.Sample{
+				Name: m.desc.Name,
+				Labels: map[string]string{
+					"iface": ifi.Name, "ip": ifi.Ip, "netns": ifi.Netns,
+					"container_id": ifi.ContainerID, "container_name": ifi.ContainerName,
+				},
+				Value: m.value(ifi),
+			})
+		}
+	}
+	for _, name := range s.IfiNames {
+		ifi := s.IfiMap[name]
+		if ifi.Bond == nil {
+			continue
+		}
+		for i := range ifi.Bond.Slaves {
+			slave := &ifi.Bond.Slaves[i]
+			for _, m := range bondSlaveMetrics {
+				samples = append(samples, collector.Sample{
+					Name:   m.desc.Name,
+					Labels: map[string]string{"bond": ifi.Name, "slave": slave.Name},
+					Value:  m.value(slave),
+				})
+			}
+		}
+	}
+	for _, name := range s.IfiNames {
+		ifi := s.IfiMap[name]
+		for i := range ifi.Queues {
+			q := &ifi.Queues[i]
+			for _, m := range queueMetrics {
+				samples = append(samples, collector.Sample{
+					Name:   m.desc.Name,
+					Labels: map[string]string{"iface": ifi.Name, "dir": q.Dir, "queue": strconv.Itoa(q.Index)},
+					Value:  m.value(q),
+				})
+			}
+		}
+	}
+	for _, name := range s.IfiNames {
+		ifi := s.IfiMap[name]
+		for _, stat := range ifi.DriverStats {
+			samples = append(samples, collector.Sample{
+				Name:   driverStatDesc.Name,
+				Labels: map[string]string{"iface": ifi.Name, "stat": stat.Name},
+				Value:  float64(stat.Value),
+			})
+		}
+		for i := range ifi.DriverCounters {
+			counter := &ifi.DriverCounters[i]
+			for _, m := range driverCounterMetrics {
+				samples = append(samples, collector.Sample{
+					Name:   m.desc.Name,
+					Labels: map[string]string{"iface": ifi.Name, "counter": counter.Name},
+					Value:  m.value(counter),
+				})
+			}
+		}
+	}
+	for _, id := range s.ContainerIDs {
+		cs := s.Containers[id]
+		for _, m := range containerMetrics {
+			samples = append(samples, collector.Sample{
+				Name:   m.desc.Name,
+				Labels: map[string]string{"container_id": cs.ID, "container_name": cs.Name},
+				Value:  m.value(cs),
+			})
+		}
+	}
+	return samples
+}
@@ -0,0 +1,104 @@
+package net
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/procfs"
+)
+
+func TestSnapshotImmutable(t *testing.T) {
+	n, now := newFixtureNetwork(t, "testdata/host/t0")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	first := n.Current()
+
+	*now = now.Add(10 * time.Second)
+	n.fs = procfs.NewFS("testdata/host/t1")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.IfiMap["eth0"].RecvByte != 1000000 || first.OutRecvByteAvg != 0 {
+		t.Fatalf("published snapshot was modified: %+v", first.IfiMap["eth0"])
+	}
+	if n.Current() == first || n.Current().IfiMap["eth0"].RecvByte != 2000000 {
+		t.Fatal("new snapshot not published")
+	}
+}
+
+func TestSnapshotConcurrentRead(t *testing.T) {
+	n, _ := newFixtureNetwork(t, "testdata/host/t0")
+	n.now = time.Now
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				n.EthRecvByteAvgFunc("0")
+				n.OutEthRecvByteAvgFunc()
+				n.Snapshot().Samples()
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := n.Collect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestLookup(t *testing.T) {
+	n, _ := newFixtureNetwork(t, "testdata/host/t0")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		selector string
+		want     string
+		err      error
+	}{
+		{"0", "eth0", nil},
+		{"1", "eth1", nil},
+		{"2", "", ErrInvalidIndex},
+		{"eth1", "eth1", nil},
+		{"name:eth0", "eth0", nil},
+		{"ifindex:3", "eth1", nil},
+		{"ifindex:9", "", ErrIfiNotFound},
+		{"ifindex:x", "", ErrInvalidSelector},
+		{"10.0.0.5", "eth1", nil},
+		{"ip:203.0.113.10", "eth0", nil},
+		{"ip:10.0.0.6", "", ErrIfiNotFound},
+		{"eth9", "", ErrIfiNotFound},
+		{"", "", ErrInvalidSelector},
+	}
+	for _, c := range cases {
+		ifi, err := n.Lookup(c.selector)
+		if err != c.err {
+			t.Errorf("Lookup(%q) err = %v, want %v", c.selector, err, c.err)
+			continue
+		}
+		if err == nil && ifi.Name != c.want {
+			t.Errorf("Lookup(%q) = %v, want %v", c.selector, ifi.Name, c.want)
+		}
+	}
+
+	if got := n.EthRecvByteAvgFunc("name:eth1"); got != 0 {
+		t.Errorf("EthRecvByteAvgFunc = %v", got)
+	}
+}
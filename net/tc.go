@@ -0,0 +1,197 @@
+package net
+
+import (
+	"encoding/binary"
+	"errors"
+	"unsafe"
+)
+
+var errShortTcMsg = errors.New("short tc message")
+
+const (
+	tcHRoot     = 0xFFFFFFFF //TC_H_ROOT
+	tcHIngress  = 0xFFFFFFF1 //TC_H_INGRESS
+	tcHMinIngr  = 0xFFF2     //TC_H_MIN_INGRESS
+	tcHMinEgr   = 0xFFF3     //TC_H_MIN_EGRESS
+	tcHMajMask  = 0xFFFF0000
+	tcaKind     = 1 //TCA_KIND
+	tcaOptions  = 2 //TCA_OPTIONS
+	tcmsgLen    = 20
+	rtaHdrLen   = 4
+	nlaTypeMask = 0x3FFF //去掉NLA_F_NESTED和NLA_F_NET_BYTEORDER
+
+	tcaTbfParms      = 1 //TCA_TBF_PARMS
+	tcaTbfRate64     = 4 //TCA_TBF_RATE64
+	tcaHtbParms      = 1 //TCA_HTB_PARMS
+	tcaHtbCeil64     = 7 //TCA_HTB_CEIL64
+	tcaCakeBaseRate  = 2 //TCA_CAKE_BASE_RATE64
+	tcaActKind       = 1 //TCA_ACT_KIND
+	tcaActOptions    = 2 //TCA_ACT_OPTIONS
+	tcaPoliceTbf     = 1 //TCA_POLICE_TBF
+	tcaPoliceRate64  = 8 //TCA_POLICE_RATE64
+	tcRatespecRate   = 8 //struct tc_ratespec中rate的偏移
+	tcRatespecLen    = 12
+	tcPoliceRateOff  = 20 //struct tc_police中rate的偏移
+	tcHtbOptCeilOff  = tcRatespecLen
+	tcTbfQoptRateOff = 0
+)
+
+// nativeEndian netlink消息使用主机字节序
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// filterPolice 各分类器中police和action属性的编号
+var filterPolice = map[string]struct{ police, act uint16 }{
+	"u32":      {police: 6, act: 7},
+	"fw":       {police: 2, act: 4},
+	"route":    {police: 5, act: 6},
+	"basic":    {police: 4, act: 3},
+	"matchall": {act: 2},
+	"flower":   {act: 3},
+}
+
+// tcObject qdisc、class或filter
+type tcObject struct {
+	ifindex int
+	handle  uint32
+	parent  uint32
+	kind    string
+	options []byte //TCA_OPTIONS
+}
+
+// parseTcMsg 解析struct tcmsg及其后的属性
+func parseTcMsg(b []byte) (tcObject, error) {
+	if len(b) < tcmsgLen {
+		return tcObject{}, errShortTcMsg
+	}
+	obj := tcObject{
+		ifindex: int(int32(nativeEndian.Uint32(b[4:8]))),
+		handle:  nativeEndian.Uint32(b[8:12]),
+		parent:  nativeEndian.Uint32(b[12:16]),
+	}
+	attrs := parseAttrs(b[tcmsgLen:])
+	obj.kind = cString(attrs[tcaKind])
+	obj.options = attrs[tcaOptions]
+	return obj, nil
+}
+
+// parseAttrs 解析struct rtattr列表, 同一类型出现多次时保留最后一个
+func parseAttrs(b []byte) map[uint16][]byte {
+	attrs := make(map[uint16][]byte)
+	for _, a := range parseAttrList(b) {
+		attrs[a.typ] = a.data
+	}
+	return attrs
+}
+
+type rtAttr struct {
+	typ  uint16
+	data []byte
+}
+
+// parseAttrList 按顺序解析struct rtattr列表, 遇到长度错误时停止
+func parseAttrList(b []byte) []rtAttr {
+	var list []rtAttr
+	for len(b) >= rtaHdrLen {
+		l := int(nativeEndian.Uint16(b[0:2]))
+		if l < rtaHdrLen || l > len(b) {
+			break
+		}
+		list = append(list, rtAttr{typ: nativeEndian.Uint16(b[2:4]) & nlaTypeMask, data: b[rtaHdrLen:l]})
+		l = (l + rtaHdrLen - 1) &^ (rtaHdrLen - 1)
+		if l > len(b) {
+			break
+		}
+		b = b[l:]
+	}
+	return list
+}
+
+// rateAttr 优先取64位速率, 没有时取tc_ratespec中的32位速率, 单位字节/秒
+func rateAttr(attrs map[uint16][]byte, rate64 uint16, parms uint16, off int) uint64 {
+	if v := attrs[rate64]; len(v) >= 8 {
+		return nativeEndian.Uint64(v)
+	}
+	if v := attrs[parms]; len(v) >= off+tcRatespecRate+4 {
+		return uint64(nativeEndian.Uint32(v[off+tcRatespecRate:]))
+	}
+	return 0
+}
+
+// qdiscRate 整形qdisc的限速, 单位字节/秒, 0表示不限速
+func qdiscRate(obj tcObject) uint64 {
+	attrs := parseAttrs(obj.options)
+	switch obj.kind {
+	case "tbf":
+		return rateAttr(attrs, tcaTbfRate64, tcaTbfParms, tcTbfQoptRateOff)
+	case "cake":
+		if v := attrs[tcaCakeBaseRate]; len(v) >= 8 {
+			return nativeEndian.Uint64(v)
+		}
+	}
+	return 0
+}
+
+// htbCeil htb class的ceil, 单位字节/秒
+func htbCeil(obj tcObject) uint64 {
+	if obj.kind != "htb" {
+		return 0
+	}
+	return rateAttr(parseAttrs(obj.options), tcaHtbCeil64, tcaHtbParms, tcHtbOptCeilOff)
+}
+
+// policeRate police的限速, 单位字节/秒
+func policeRate(options []byte) uint64 {
+	return rateAttr(parseAttrs(options), tcaPoliceRate64, tcaPoliceTbf, tcPoliceRateOff)
+}
+
+// filterPoliceRate filter上所有police中最严格的限速, 单位字节/秒
+func filterPoliceRate(obj tcObject) uint64 {
+	ids, exists := filterPolice[obj.kind]
+	if !exists {
+		return 0
+	}
+
+	var rates []uint64
+	attrs := parseAttrs(obj.options)
+	if ids.police != 0 {
+		if v, exists := attrs[ids.police]; exists {
+			rates = append(rates, policeRate(v))
+		}
+	}
+	if v, exists := attrs[ids.act]; exists {
+		for _, act := range parseAttrList(v) {
+			actAttrs := parseAttrs(act.data)
+			if cString(actAttrs[tcaActKind]) == "police" {
+				rates = append(rates, policeRate(actAttrs[tcaActOptions]))
+			}
+		}
+	}
+	return minRate(rates...)
+}
+
+// minRate 最小的非0速率
+func minRate(rates ...uint64) uint64 {
+	var min uint64
+	for _, r := range rates {
+		if r != 0 && (min == 0 || r < min) {
+			min = r
+		}
+	}
+	return min
+}
+
+// cString 去掉C字符串结尾的\0
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
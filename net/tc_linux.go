@@ -0,0 +1,50 @@
+package net
+
+import (
+	"syscall"
+)
+
+// NetlinkShaper 通过netlink读取本机的tc配置
+type NetlinkShaper struct{}
+
+func (NetlinkShaper) Shapers() (map[int]Shaper, error) {
+	c, err := dialNetlink()
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+	return tcShapers(netlinkDumper{c})
+}
+
+// netlinkDumper 一个采集周期内的所有dump复用同一个netlink连接
+type netlinkDumper struct {
+	c *netlinkConn
+}
+
+func (d netlinkDumper) qdiscs() ([]tcObject, error) {
+	return d.dump(syscall.RTM_GETQDISC, 0, 0)
+}
+
+func (d netlinkDumper) classes(ifindex int) ([]tcObject, error) {
+	return d.dump(syscall.RTM_GETTCLASS, ifindex, 0)
+}
+
+func (d netlinkDumper) filters(ifindex int, parent uint32) ([]tcObject, error) {
+	return d.dump(syscall.RTM_GETTFILTER, ifindex, parent)
+}
+
+// dump 发送一次NLM_F_DUMP请求, 返回所有tc对象
+func (d netlinkDumper) dump(typ uint16, ifindex int, parent uint32) ([]tcObject, error) {
+	tcm := make([]byte, tcmsgLen)
+	tcm[0] = syscall.AF_UNSPEC
+	nativeEndian.PutUint32(tcm[4:8], uint32(int32(ifindex)))
+	nativeEndian.PutUint32(tcm[12:16], parent)
+
+	var objs []tcObject
+	err := d.c.dump(typ, tcm, func(_ uint16, data []byte) {
+		if obj, err := parseTcMsg(data); err == nil {
+			objs = append(objs, obj)
+		}
+	})
+	return objs, err
+}
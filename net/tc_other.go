@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package net
+
+import (
+	"errors"
+)
+
+var errNetlinkUnsupported = errors.New("netlink is only supported on linux")
+
+// NetlinkShaper 通过netlink读取本机的tc配置
+type NetlinkShaper struct{}
+
+func (NetlinkShaper) Shapers() (map[int]Shaper, error) {
+	return nil, errNetlinkUnsupported
+}
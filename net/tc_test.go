@@ -0,0 +1,172 @@
+package net
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// attr 构造一个struct rtattr
+func attr(typ uint16, data []byte) []byte {
+	l := rtaHdrLen + len(data)
+	b := make([]byte, (l+rtaHdrLen-1)&^(rtaHdrLen-1))
+	nativeEndian.PutUint16(b[0:2], uint16(l))
+	nativeEndian.PutUint16(b[2:4], typ)
+	copy(b[rtaHdrLen:], data)
+	return b
+}
+
+func attrs(list ...[]byte) []byte {
+	var b []byte
+	for _, a := range list {
+		b = append(b, a...)
+	}
+	return b
+}
+
+func u64(v uint64) []byte {
+	b := make([]byte, 8)
+	nativeEndian.PutUint64(b, v)
+	return b
+}
+
+// tcPolice 构造rate为指定值的struct tc_police
+func tcPolice(rate uint32) []byte {
+	b := make([]byte, 48)
+	nativeEndian.PutUint32(b[tcPoliceRateOff+tcRatespecRate:], rate)
+	return b
+}
+
+func tcMsg(ifindex int, handle, parent uint32, kind string, options []byte) []byte {
+	b := make([]byte, tcmsgLen)
+	nativeEndian.PutUint32(b[4:8], uint32(ifindex))
+	nativeEndian.PutUint32(b[8:12], handle)
+	nativeEndian.PutUint32(b[12:16], parent)
+	return append(b, attrs(attr(tcaKind, append([]byte(kind), 0)), attr(tcaOptions, options))...)
+}
+
+func mustTcObject(t *testing.T, b []byte) tcObject {
+	obj, err := parseTcMsg(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestQdiscRate(t *testing.T) {
+	//tc qdisc add dev lo root tbf rate 80mbit burst 32kbit latency 400ms
+	tbf, _ := hex.DecodeString("2800010000010000000000008096980000000000000000000000000000193d000019000000000000")
+	obj := mustTcObject(t, tcMsg(1, 0x80020000, tcHRoot, "tbf", tbf))
+	if obj.ifindex != 1 || obj.parent != tcHRoot || obj.kind != "tbf" {
+		t.Fatalf("unexpected object: %+v", obj)
+	}
+	if rate := qdiscRate(obj); bytesToMbps(rate) != 80 {
+		t.Fatalf("tbf rate = %v", rate)
+	}
+
+	cake := mustTcObject(t, tcMsg(1, 0x80030000, tcHRoot, "cake", attr(tcaCakeBaseRate, u64(12500000))))
+	if rate := qdiscRate(cake); bytesToMbps(rate) != 100 {
+		t.Fatalf("cake rate = %v", rate)
+	}
+}
+
+func TestFilterPoliceRate(t *testing.T) {
+	//matchall action police rate 40mbit
+	act := attr(1, attrs(attr(tcaActKind, []byte("police\x00")), attr(tcaActOptions, attr(tcaPoliceTbf, tcPolice(5000000)))))
+	matchall := mustTcObject(t, tcMsg(2, 1, 0xFFFF0000, "matchall", attr(2, act)))
+	if rate := filterPoliceRate(matchall); bytesToMbps(rate) != 40 {
+		t.Fatalf("matchall police rate = %v", rate)
+	}
+
+	//u32 police rate 30mbit, 64位速率优先
+	police := attrs(attr(tcaPoliceTbf, tcPolice(1)), attr(tcaPoliceRate64, u64(3750000)))
+	u32 := mustTcObject(t, tcMsg(2, 2, 0xFFFF0000, "u32", attr(6, police)))
+	if rate := filterPoliceRate(u32); bytesToMbps(rate) != 30 {
+		t.Fatalf("u32 police rate = %v", rate)
+	}
+
+	mirred := attr(1, attrs(attr(tcaActKind, []byte("mirred\x00")), attr(tcaActOptions, nil)))
+	if rate := filterPoliceRate(mustTcObject(t, tcMsg(2, 3, 0xFFFF0000, "matchall", attr(2, mirred)))); rate != 0 {
+		t.Fatalf("mirred rate = %v", rate)
+	}
+}
+
+type fakeDumper struct {
+	qdisc   []tcObject
+	class   map[int][]tcObject
+	filter  map[tcParent][]tcObject
+	queries map[tcParent]int //filter查询次数
+}
+
+type tcParent struct {
+	ifindex int
+	parent  uint32
+}
+
+func (d fakeDumper) qdiscs() ([]tcObject, error) { return d.qdisc, nil }
+
+func (d fakeDumper) classes(ifindex int) ([]tcObject, error) { return d.class[ifindex], nil }
+
+func (d fakeDumper) filters(ifindex int, parent uint32) ([]tcObject, error) {
+	if d.queries != nil {
+		d.queries[tcParent{ifindex, parent}]++
+	}
+	return d.filter[tcParent{ifindex, parent}], nil
+}
+
+func TestTcShapers(t *testing.T) {
+	htbCeil := func(ceil uint64) []byte {
+		return attrs(attr(tcaHtbParms, make([]byte, 44)), attr(tcaHtbCeil64, u64(ceil)))
+	}
+	police := func(rate uint32) []byte {
+		return attr(2, attr(1, attrs(attr(tcaActKind, []byte("police\x00")), attr(tcaActOptions, attr(tcaPoliceTbf, tcPolice(rate))))))
+	}
+
+	d := fakeDumper{
+		qdisc: []tcObject{
+			{ifindex: 2, handle: 0x10000, parent: tcHRoot, kind: "htb"},
+			{ifindex: 2, handle: 0xFFFF0000, parent: tcHIngress, kind: "ingress"},
+			{ifindex: 3, handle: 0xFFFF0000, parent: tcHIngress, kind: "clsact"},
+			{ifindex: 4, handle: 0, parent: tcHRoot, kind: "pfifo_fast"},
+			{ifindex: 5, handle: 0, parent: tcHRoot, kind: "noqueue"},
+		},
+		class: map[int][]tcObject{
+			2: {
+				{ifindex: 2, handle: 0x10001, parent: tcHRoot, kind: "htb", options: htbCeil(12500000)},
+				{ifindex: 2, handle: 0x10002, parent: tcHRoot, kind: "htb", options: htbCeil(2500000)},
+				{ifindex: 2, handle: 0x10010, parent: 0x10001, kind: "htb", options: htbCeil(125000)},
+			},
+		},
+		filter: map[tcParent][]tcObject{
+			{2, 0xFFFF0000}:              {{kind: "matchall", options: police(5000000)}, {kind: "matchall", options: police(2500000)}},
+			{3, 0xFFFF0000 | tcHMinIngr}: {{kind: "matchall", options: police(1250000)}},
+			{3, 0xFFFF0000 | tcHMinEgr}:  {{kind: "matchall", options: police(2500000)}},
+		},
+		queries: make(map[tcParent]int),
+	}
+
+	shapers, err := tcShapers(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]Shaper{
+		2: {RecvLimit: 20, RecvSource: LimitSourcePolice, SendLimit: 120, SendSource: LimitSourceHTB},
+		3: {RecvLimit: 10, RecvSource: LimitSourcePolice, SendLimit: 20, SendSource: LimitSourcePolice},
+	}
+	if len(shapers) != len(want) {
+		t.Fatalf("got %+v, want %+v", shapers, want)
+	}
+	for index, s := range want {
+		if shapers[index] != s {
+			t.Errorf("ifindex %d: got %+v, want %+v", index, shapers[index], s)
+		}
+	}
+	//不限速的根qdisc不查询filter
+	for q := range d.queries {
+		if q.ifindex == 4 || q.ifindex == 5 {
+			t.Errorf("filters queried under %+v", q)
+		}
+	}
+	if len(d.queries) != 4 {
+		t.Errorf("filter queries = %v", d.queries)
+	}
+}
@@ -0,0 +1,267 @@
+package net
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	bondSlaveActive = "active"
+	miiStatusUp     = "up"
+)
+
+// BondInfo bond网卡及其从属网卡的状态, 发布后不可修改
+type BondInfo struct {
+	Mode        string      `json:"mode"`         //bond模式 balance-rr/active-backup/802.3ad等
+	MiiStatus   string      `json:"mii_status"`   //bond链路状态 up/down
+	ActiveSlave string      `json:"active_slave"` //当前活动的从属网卡, 只有active-backup等模式才有
+	Slaves      []BondSlave `json:"slaves"`       //从属网卡, 按bonding/slaves中的顺序
+}
+
+// BondSlave bond从属网卡的状态
+type BondSlave struct {
+	Name             string `json:"name"`               //网卡名
+	State            string `json:"state"`              //active/backup
+	MiiStatus        string `json:"mii_status"`         //链路状态 up/down
+	LinkFailures     uint64 `json:"link_failures"`      //链路故障累计次数
+	LinkFailureDelta uint64 `json:"link_failure_delta"` //一个周期内链路故障次数
+}
+
+// TopologyLink 两个网卡之间的上下层关系, 与内核的upper/lower一致:
+// bond/team的流量是下层网卡流量之和; 网桥网卡只统计到达本机的流量, 转发的流量只经过网桥端口;
+// VLAN的流量包含在下层网卡的流量中
+type TopologyLink struct {
+	Upper string //上层网卡 bond/team/bridge/VLAN
+	Lower string //下层网卡 从属网卡/网桥端口/VLAN的父网卡
+	Kind  string //关系类型, 为上层网卡的类型, 未采集上层网卡时为master
+}
+
+// linkTopology 网卡在sysfs中的上下层关系
+type linkTopology struct {
+	Master   string    //bond/team/bridge的从属网卡或端口所属的主网卡
+	Lower    string    //VLAN的父网卡
+	Bond     *BondInfo //bond网卡的状态, 其他网卡为nil
+	Carriers []string  //承载网卡流量的网卡, 见readCarriers
+}
+
+// readTopology 读取网卡的主网卡、VLAN父网卡和bond状态, prev为上次的bond状态, 用于计算一个周期内的链路故障次数
+func (n *NetWork) readTopology(name, typ string, prev *BondInfo) linkTopology {
+	var topo linkTopology
+	dir := n.fs.Sys("class", "net", name)
+	topo.Master = readMaster(dir)
+	if typ == LinkTypeVlan {
+		topo.Lower = n.readLower(dir)
+	}
+	if typ == LinkTypeBond {
+		topo.Bond = n.readBond(name, prev)
+	}
+	topo.Carriers = n.readCarriers(name, typ, topo.Master, topo.Lower)
+	return topo
+}
+
+func readMaster(dir string) string {
+	if target, err := os.Readlink(dir + "/master"); err == nil {
+		return filepath.Base(target)
+	}
+	return ""
+}
+
+// readCarriers 沿sysfs逐级找出承载网卡流量的所有网卡, 由近及远:
+// bond/team从属网卡的流量由master承载; VLAN的流量由父网卡承载, 网桥的流量由网桥端口承载, 即沿lower_链接向下,
+// 但bond/team的lower_链接指向自己的从属网卡, 不是承载网卡.
+// 网桥端口不由网桥承载, 因为转发的流量不经过网桥网卡.
+// 承载链只取决于sysfs, 与哪些网卡被采集、以什么顺序遍历无关
+func (n *NetWork) readCarriers(name, typ, master, lower string) []string {
+	var next []string
+	if master != "" && n.aggregatesSlaves(master) {
+		next = append(next, master)
+	}
+	if lower != "" {
+		next = append(next, lower)
+	}
+	if typ == LinkTypeBridge {
+		next = append(next, n.readLowers(name)...)
+	}
+	if len(next) == 0 {
+		return nil
+	}
+
+	var carriers []string
+	seen := map[string]bool{name: true}
+	for len(next) > 0 {
+		carrier := next[0]
+		next = next[1:]
+		if seen[carrier] {
+			continue
+		}
+		seen[carrier] = true
+		carriers = append(carriers, carrier)
+
+		if m := readMaster(n.fs.Sys("class", "net", carrier)); m != "" && n.aggregatesSlaves(m) {
+			next = append(next, m)
+		}
+		lowers := n.readLowers(carrier)
+		if len(lowers) > 0 && n.aggregatesSlaves(carrier) {
+			continue
+		}
+		next = append(next, lowers...)
+	}
+	return carriers
+}
+
+// aggregatesSlaves 网卡是否为bond/team, 其流量是从属网卡流量之和
+func (n *NetWork) aggregatesSlaves(name string) bool {
+	if _, err := os.Stat(n.fs.Sys("class", "net", name, "bonding")); err == nil {
+		return true
+	}
+	return n.readDriverInfo(name).Driver == "team"
+}
+
+// readLowers 网卡的所有lower_链接: VLAN的父网卡, bond/team的从属网卡或网桥端口
+func (n *NetWork) readLowers(name string) []string {
+	entries, err := os.ReadDir(n.fs.Sys("class", "net", name))
+	if err != nil {
+		return nil
+	}
+	var lowers []string
+	for _, e := range entries {
+		if lower := strings.TrimPrefix(e.Name(), "lower_"); lower != e.Name() {
+			lowers = append(lowers, lower)
+		}
+	}
+	return lowers
+}
+
+// readLower VLAN只有一个lower_<父网卡>链接
+func (n *NetWork) readLower(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if lower := strings.TrimPrefix(e.Name(), "lower_"); lower != e.Name() {
+			return lower
+		}
+	}
+	return ""
+}
+
+// readBond 读取/sys/class/net/<bond>/bonding和各从属网卡的bonding_slave
+func (n *NetWork) readBond(name string, prev *BondInfo) *BondInfo {
+	dir := n.fs.Sys("class", "net", name, "bonding")
+	bond := &BondInfo{}
+	if mode, err := n.fs.ReadString(filepath.Join(dir, "mode")); err == nil {
+		//例如 active-backup 1
+		if fields := strings.Fields(mode); len(fields) > 0 {
+			bond.Mode = fields[0]
+		}
+	}
+	bond.MiiStatus, _ = n.fs.ReadString(filepath.Join(dir, "mii_status"))
+	bond.ActiveSlave, _ = n.fs.ReadString(filepath.Join(dir, "active_slave"))
+
+	slaves, _ := n.fs.ReadString(filepath.Join(dir, "slaves"))
+	for _, slave := range strings.Fields(slaves) {
+		sdir := n.fs.Sys("class", "net", slave, "bonding_slave")
+		s := BondSlave{Name: slave}
+		s.State, _ = n.fs.ReadString(filepath.Join(sdir, "state"))
+		s.MiiStatus, _ = n.fs.ReadString(filepath.Join(sdir, "mii_status"))
+		s.LinkFailures, _ = n.fs.ReadUint(filepath.Join(sdir, "link_failure_count"))
+		if p := prev.slave(slave); p != nil && s.LinkFailures >= p.LinkFailures {
+			s.LinkFailureDelta = s.LinkFailures - p.LinkFailures
+		}
+		bond.Slaves = append(bond.Slaves, s)
+	}
+	return bond
+}
+
+func (b *BondInfo) slave(name string) *BondSlave {
+	if b == nil {
+		return nil
+	}
+	for i := range b.Slaves {
+		if b.Slaves[i].Name == name {
+			return &b.Slaves[i]
+		}
+	}
+	return nil
+}
+
+// covered 网卡的流量已包含在其他被采集的网卡中时, 网卡不计入整机统计.
+// 规则只有一条: 承载链(Carriers)上任一网卡被采集即不计入, 因此bond/team只统计bond/team而不统计从属网卡,
+// VLAN只统计父网卡, 网桥只统计网桥端口而不统计网桥网卡.
+// VLAN的父网卡是未被采集的bond/team时, VLAN的流量由父网卡被采集的从属网卡承载,
+// 例如忽略bond0时统计eth0和eth1, 不再统计bond0.100
+func (s *Snapshot) covered(ifi *Ifi) bool {
+	for _, carrier := range ifi.Carriers {
+		if _, exists := s.IfiMap[carrier]; exists {
+			return true
+		}
+	}
+	if ifi.Lower == "" || ifi.Netns != "" {
+		return false
+	}
+	for _, key := range s.IfiNames {
+		other := s.IfiMap[key]
+		if other.Lower != "" || other.Netns != "" {
+			continue
+		}
+		if containsString(other.Carriers, ifi.Lower) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// topology 由本机命名空间网卡的上下层关系生成拓扑, 包含未被采集的bond从属网卡
+func (s *Snapshot) topology() []TopologyLink {
+	seen := make(map[TopologyLink]bool)
+	var links []TopologyLink
+	add := func(l TopologyLink) {
+		if !seen[l] {
+			seen[l] = true
+			links = append(links, l)
+		}
+	}
+	kind := func(upper string) string {
+		if ifi, exists := s.IfiMap[upper]; exists && ifi.Type != "" {
+			return ifi.Type
+		}
+		return "master"
+	}
+
+	for _, key := range s.IfiNames {
+		ifi := s.IfiMap[key]
+		if ifi.Netns != "" {
+			continue
+		}
+		if ifi.Master != "" {
+			add(TopologyLink{Upper: ifi.Master, Lower: ifi.Name, Kind: kind(ifi.Master)})
+		}
+		if ifi.Lower != "" {
+			add(TopologyLink{Upper: ifi.Name, Lower: ifi.Lower, Kind: LinkTypeVlan})
+		}
+		if ifi.Bond != nil {
+			for _, slave := range ifi.Bond.Slaves {
+				add(TopologyLink{Upper: ifi.Name, Lower: slave.Name, Kind: LinkTypeBond})
+			}
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Upper != links[j].Upper {
+			return links[i].Upper < links[j].Upper
+		}
+		return links[i].Lower < links[j].Lower
+	})
+	return links
+}
@@ -0,0 +1,249 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/procfs"
+)
+
+// writeTopologyDev 写入/proc/net/dev, recv为各网卡的接收字节数
+func writeTopologyDev(t *testing.T, root string, recv map[string]uint64) {
+	dir := filepath.Join(root, "proc", "net")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dev := "Inter-|   Receive                                                |  Transmit\n" +
+		" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n"
+	for _, name := range []string{"eth0", "eth1", "bond0", "bond0.100", "br0", "tap0", "eth0.100"} {
+		dev += fmt.Sprintf("%s: %d 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0\n", name, recv[name])
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dev"), []byte(dev), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlinkSys(t *testing.T, root, name, link, target string) {
+	if err := os.Symlink(target, filepath.Join(root, "sys", "class", "net", name, link)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTopologyNetwork(t *testing.T) (*NetWork, string, *time.Time) {
+	root := t.TempDir()
+	sys := filepath.Join(root, "sys", "class", "net")
+
+	writeSysFile(t, root, "bond0", "bonding/mode", "active-backup 1")
+	writeSysFile(t, root, "bond0", "bonding/mii_status", "up")
+	writeSysFile(t, root, "bond0", "bonding/active_slave", "eth0")
+	writeSysFile(t, root, "bond0", "bonding/slaves", "eth0 eth1")
+	writeSysFile(t, root, "eth0", "bonding_slave/state", "active")
+	writeSysFile(t, root, "eth0", "bonding_slave/mii_status", "up")
+	writeSysFile(t, root, "eth0", "bonding_slave/link_failure_count", "1")
+	writeSysFile(t, root, "eth1", "bonding_slave/state", "backup")
+	writeSysFile(t, root, "eth1", "bonding_slave/mii_status", "down")
+	writeSysFile(t, root, "eth1", "bonding_slave/link_failure_count", "5")
+	symlinkSys(t, root, "eth0", "master", "../bond0")
+	symlinkSys(t, root, "eth1", "master", "../bond0")
+	symlinkSys(t, root, "bond0", "lower_eth0", "../eth0")
+	symlinkSys(t, root, "bond0", "lower_eth1", "../eth1")
+
+	writeSysFile(t, root, "bond0.100", "uevent", "DEVTYPE=vlan\nINTERFACE=bond0.100")
+	symlinkSys(t, root, "bond0.100", "lower_bond0", "../bond0")
+
+	if err := os.MkdirAll(filepath.Join(sys, "br0", "bridge"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeSysFile(t, root, "tap0", "tun_flags", "0x1002")
+	symlinkSys(t, root, "tap0", "master", "../br0")
+	symlinkSys(t, root, "br0", "lower_tap0", "../tap0")
+
+	writeTopologyDev(t, root, map[string]uint64{})
+
+	r := StaticResolver{}
+	for i, name := range []string{"eth0", "eth1", "bond0", "bond0.100", "br0", "tap0"} {
+		r[name] = &Link{Index: i + 2, Name: name, Addrs: []net.Addr{mustCIDR(t, fmt.Sprintf("203.0.113.%d/24", i+10))}}
+	}
+
+	now := time.Unix(1600000000, 0)
+	n := NewNetwork([]string{}, []string{"lo"}, []string{}, []string{}, WithRoot(root), WithResolver(r))
+	n.now = func() time.Time { return now }
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return n, root, &now
+}
+
+func TestTopologyTotals(t *testing.T) {
+	n, root, now := newTopologyNetwork(t)
+
+	writeTopologyDev(t, root, map[string]uint64{
+		"eth0": 15000, "eth1": 5000, "bond0": 20000, "bond0.100": 8000, "br0": 3000, "tap0": 3000,
+	})
+	writeSysFile(t, root, "eth0", "bonding_slave/link_failure_count", "3")
+	*now = now.Add(10 * time.Second)
+	n.fs = procfs.NewFS(root)
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s := n.Current()
+
+	//只统计bond0和网桥端口tap0
+	if s.OutRecvByteAvg != 2300 {
+		t.Fatalf("OutRecvByteAvg = %v, want 2300", s.OutRecvByteAvg)
+	}
+	counted := map[string]bool{"bond0": true, "tap0": true}
+	for _, name := range s.IfiNames {
+		if got := s.IfiMap[name].Counted; got != counted[name] {
+			t.Errorf("%s counted = %v", name, got)
+		}
+	}
+
+	eth0, bond := s.IfiMap["eth0"], s.IfiMap["bond0"]
+	if eth0.Master != "bond0" || s.IfiMap["bond0.100"].Lower != "bond0" || s.IfiMap["tap0"].Master != "br0" {
+		t.Fatalf("unexpected relations: eth0 master=%q vlan lower=%q", eth0.Master, s.IfiMap["bond0.100"].Lower)
+	}
+	if bond.Bond == nil || bond.Bond.Mode != "active-backup" || bond.Bond.ActiveSlave != "eth0" || len(bond.Bond.Slaves) != 2 {
+		t.Fatalf("unexpected bond: %+v", bond.Bond)
+	}
+	want := []BondSlave{
+		{Name: "eth0", State: "active", MiiStatus: "up", LinkFailures: 3, LinkFailureDelta: 2},
+		{Name: "eth1", State: "backup", MiiStatus: "down", LinkFailures: 5},
+	}
+	for i := range want {
+		if bond.Bond.Slaves[i] != want[i] {
+			t.Errorf("slave %d = %+v, want %+v", i, bond.Bond.Slaves[i], want[i])
+		}
+	}
+
+	links := []TopologyLink{
+		{Upper: "bond0", Lower: "eth0", Kind: LinkTypeBond},
+		{Upper: "bond0", Lower: "eth1", Kind: LinkTypeBond},
+		{Upper: "bond0.100", Lower: "bond0", Kind: LinkTypeVlan},
+		{Upper: "br0", Lower: "tap0", Kind: LinkTypeBridge},
+	}
+	if len(s.Topology) != len(links) {
+		t.Fatalf("got topology %+v", s.Topology)
+	}
+	for i := range links {
+		if s.Topology[i] != links[i] {
+			t.Errorf("link %d = %+v, want %+v", i, s.Topology[i], links[i])
+		}
+	}
+
+	samples := 0
+	for _, sample := range s.Samples() {
+		if sample.Name == "net_bond_slave_up" {
+			samples++
+			up := sample.Labels["slave"] == "eth0"
+			if sample.Labels["bond"] != "bond0" || (sample.Value == 1) != up {
+				t.Errorf("unexpected sample %+v", sample)
+			}
+		}
+	}
+	if samples != 2 {
+		t.Fatalf("got %d net_bond_slave_up samples, want 2", samples)
+	}
+}
+
+func TestTopologyParentNotCollected(t *testing.T) {
+	n, root, now := newTopologyNetwork(t)
+	n.IgnoreEth = []string{"lo", "bond[0]", "br0"}
+
+	writeTopologyDev(t, root, map[string]uint64{
+		"eth0": 15000, "eth1": 5000, "bond0": 20000, "bond0.100": 8000, "br0": 3000, "tap0": 3000,
+	})
+	*now = now.Add(10 * time.Second)
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	//bond0和br0被忽略时统计从属网卡和端口, bond0.100的流量已包含在eth0和eth1中
+	if got := n.Current().OutRecvByteAvg; got != 1500+500+300 {
+		t.Fatalf("OutRecvByteAvg = %v, want 2300", got)
+	}
+}
+
+func TestTopologyStackedVlan(t *testing.T) {
+	n, root, now := newTopologyNetwork(t)
+	//bond从属网卡上的VLAN: eth0.100 -> eth0 -> bond0
+	writeSysFile(t, root, "eth0.100", "uevent", "DEVTYPE=vlan\nINTERFACE=eth0.100")
+	symlinkSys(t, root, "eth0.100", "lower_eth0", "../eth0")
+	n.resolver.(StaticResolver)["eth0.100"] = &Link{Index: 20, Name: "eth0.100", Addrs: []net.Addr{mustCIDR(t, "203.0.113.20/24")}}
+
+	cases := []struct {
+		ignore  []string
+		counted []string
+	}{
+		{nil, []string{"bond0", "tap0"}},
+		{[]string{"bond[0]"}, []string{"eth0", "eth1", "tap0"}},
+		{[]string{"eth[0]"}, []string{"bond0", "tap0"}},
+		{[]string{"bond[0]", "eth[0]"}, []string{"eth0.100", "eth1", "tap0"}},
+		{[]string{"bond[0]", "eth[01]"}, []string{"bond0.100", "eth0.100", "tap0"}},
+	}
+	for _, c := range cases {
+		n.IgnoreEth = append([]string{"lo"}, c.ignore...)
+		*now = now.Add(10 * time.Second)
+		if err := n.Collect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		s := n.Current()
+
+		if got := s.IfiMap["eth0.100"]; got != nil && fmt.Sprint(got.Carriers) != "[eth0 bond0]" {
+			t.Errorf("eth0.100 carriers = %v", got.Carriers)
+		}
+		if got := s.IfiMap["bond0.100"]; fmt.Sprint(got.Carriers) != "[bond0]" {
+			t.Errorf("bond0.100 carriers = %v", got.Carriers)
+		}
+		var counted []string
+		for _, name := range s.IfiNames {
+			if s.IfiMap[name].Counted {
+				counted = append(counted, name)
+			}
+		}
+		if fmt.Sprint(counted) != fmt.Sprint(c.counted) {
+			t.Errorf("ignore %v: counted %v, want %v", c.ignore, counted, c.counted)
+		}
+	}
+}
+
+func TestTopologyBridgedNic(t *testing.T) {
+	//物理网卡eth0是网桥br0的端口, 转发的流量不经过br0
+	root := t.TempDir()
+	writeSysFile(t, root, "eth0", "address", "52:54:00:00:00:01")
+	if err := os.MkdirAll(filepath.Join(root, "sys", "class", "net", "br0", "bridge"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	symlinkSys(t, root, "eth0", "master", "../br0")
+	symlinkSys(t, root, "br0", "lower_eth0", "../eth0")
+	writeTopologyDev(t, root, map[string]uint64{})
+
+	r := StaticResolver{}
+	for i, name := range []string{"eth0", "br0"} {
+		r[name] = &Link{Index: i + 2, Name: name, Addrs: []net.Addr{mustCIDR(t, fmt.Sprintf("203.0.113.%d/24", i+10))}}
+	}
+	now := time.Unix(1600000000, 0)
+	n := NewNetwork([]string{}, []string{"lo"}, []string{}, []string{}, WithRoot(root), WithResolver(r))
+	n.now = func() time.Time { return now }
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	writeTopologyDev(t, root, map[string]uint64{"eth0": 50000, "br0": 1000})
+	now = now.Add(10 * time.Second)
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s := n.Current()
+	if eth0, br0 := s.IfiMap["eth0"], s.IfiMap["br0"]; !eth0.Counted || br0.Counted {
+		t.Fatalf("eth0 counted = %v, br0 counted = %v", eth0.Counted, br0.Counted)
+	}
+	if s.OutRecvByteAvg != 5000 {
+		t.Fatalf("OutRecvByteAvg = %v, want 5000", s.OutRecvByteAvg)
+	}
+}
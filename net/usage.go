@@ -0,0 +1,60 @@
+package net
+
+// speedOverride 按网卡名指定速率, 用于速率未知或不准确的虚拟网卡
+type speedOverride struct {
+	pattern namePatterns
+	speed   float64 //Mb/s
+}
+
+// overrideSpeed 返回第一个匹配的指定速率, 没有匹配时返回原速率
+func (n *NetWork) overrideSpeed(name string, speed float64) float64 {
+	for _, o := range n.speedOverrides {
+		if o.pattern.match(name) {
+			return o.speed
+		}
+	}
+	return speed
+}
+
+// useRate 带宽使用率(%), 速率按10^6计算Mb/s, 速率未知时返回0
+func useRate(byteAvg, speed float64) float64 {
+	if speed <= 0 {
+		return 0
+	}
+	return byteAvg * 8 * 100 / (speed * 1e6)
+}
+
+// updateUseRate 根据当前速率计算收发带宽使用率, 须在设置Speed之后调用
+func (n *Ifi) updateUseRate() {
+	n.RecvUseRate = useRate(n.RecvByteAvg, n.Speed)
+	n.SendUseRate = useRate(n.SendByteAvg, n.Speed)
+}
+
+// useRateStat 一个区域的带宽使用率统计
+type useRateStat struct {
+	recvMax, recvSum float64
+	sendMax, sendSum float64
+	num              int //速率已知的网卡数
+}
+
+func (u *useRateStat) add(ifi *Ifi) {
+	if ifi.Speed <= 0 {
+		return
+	}
+	if ifi.RecvUseRate > u.recvMax {
+		u.recvMax = ifi.RecvUseRate
+	}
+	if ifi.SendUseRate > u.sendMax {
+		u.sendMax = ifi.SendUseRate
+	}
+	u.recvSum += ifi.RecvUseRate
+	u.sendSum += ifi.SendUseRate
+	u.num++
+}
+
+func (u *useRateStat) avg() (recv, send float64) {
+	if u.num == 0 {
+		return 0, 0
+	}
+	return u.recvSum / float64(u.num), u.sendSum / float64(u.num)
+}
@@ -0,0 +1,102 @@
+package net
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/procfs"
+)
+
+func collectUsage(t *testing.T, opts ...Option) *Snapshot {
+	n, now := newFixtureNetwork(t, "testdata/host/t0")
+	for _, opt := range opts {
+		opt(n)
+	}
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(10 * time.Second)
+	n.fs = procfs.NewFS("testdata/host/t1")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return n.Current()
+}
+
+func TestUseRate(t *testing.T) {
+	snap := collectUsage(t)
+
+	//eth0: 1000Mb/s, 收100000byte/s, 发200000byte/s
+	eth0 := snap.IfiMap["eth0"]
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"eth0.RecvUseRate", eth0.RecvUseRate, 0.08},
+		{"eth0.SendUseRate", eth0.SendUseRate, 0.16},
+		{"OutRecvMaxUseRate", snap.OutRecvMaxUseRate, 0.08},
+		{"OutSendAvgUseRate", snap.OutSendAvgUseRate, 0.16},
+		{"EthInMaxUseRate", snap.EthInMaxUseRate, 0.08},
+		{"EthOutMaxUseRate", snap.EthOutMaxUseRate, 0.16},
+		//eth1速率未知, 不参与统计
+		{"eth1.RecvUseRate", snap.IfiMap["eth1"].RecvUseRate, 0},
+		{"InRecvMaxUseRate", snap.InRecvMaxUseRate, 0},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	for _, sample := range snap.Samples() {
+		if sample.Name == "net_iface_recv_use_rate" && sample.Labels["iface"] == "eth1" {
+			t.Fatalf("unexpected use rate sample for eth1 with unknown speed")
+		}
+	}
+}
+
+func TestSpeedOverride(t *testing.T) {
+	snap := collectUsage(t, WithSpeedOverride("eth1", 100), WithSpeedOverride("eth*", 1))
+
+	//eth1: 收10000byte/s, 发5000byte/s
+	eth1 := snap.IfiMap["eth1"]
+	if eth1.Speed != 100 {
+		t.Fatalf("eth1 speed = %v, want 100", eth1.Speed)
+	}
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"eth1.RecvUseRate", eth1.RecvUseRate, 0.08},
+		{"eth1.SendUseRate", eth1.SendUseRate, 0.04},
+		{"InRecvMaxUseRate", snap.InRecvMaxUseRate, 0.08},
+		{"InRecvAvgUseRate", snap.InRecvAvgUseRate, 0.08},
+		{"InSendMaxUseRate", snap.InSendMaxUseRate, 0.04},
+		//eth0匹配eth*
+		{"eth0.RecvUseRate", snap.IfiMap["eth0"].RecvUseRate, 80},
+		{"EthInMaxUseRate", snap.EthInMaxUseRate, 80},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestUseRateNotRatcheted(t *testing.T) {
+	n := collectFixture(t)
+	if n.Current().EthOutMaxUseRate == 0 {
+		t.Fatal("expected nonzero use rate")
+	}
+
+	//流量为0的周期使用率回落
+	n.now = func() time.Time { return time.Unix(1600000100, 0) }
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.Current().EthOutMaxUseRate; got != 0 {
+		t.Fatalf("EthOutMaxUseRate = %v after idle cycle, want 0", got)
+	}
+}
@@ -0,0 +1,279 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"time"
+)
+
+// rtnetlink消息类型和属性, 与linux内核定义相同
+const (
+	rtmNewLink = 16
+	rtmDelLink = 17
+	rtmNewAddr = 20
+	rtmDelAddr = 21
+
+	iflaIfname   = 3
+	ifinfomsgLen = 16
+	ifaddrmsgLen = 8
+)
+
+var errShortLinkMsg = errors.New("short rtnetlink message")
+
+// linkMessage 一条RTM_NEWLINK/RTM_DELLINK/RTM_NEWADDR/RTM_DELADDR消息
+type linkMessage struct {
+	typ   uint16
+	index int
+	name  string    //只有链路消息带网卡名
+	attrs linkAttrs //RTM_NEWLINK的链路属性
+	addr  net.Addr  //地址消息中的地址, 无法解析时为nil
+}
+
+// parseLinkMessage 解析struct ifinfomsg或struct ifaddrmsg及其后的属性
+func parseLinkMessage(typ uint16, data []byte) (linkMessage, error) {
+	msg := linkMessage{typ: typ}
+	switch typ {
+	case rtmNewLink, rtmDelLink:
+		if len(data) < ifinfomsgLen {
+			return msg, errShortLinkMsg
+		}
+		msg.index = int(int32(nativeEndian.Uint32(data[4:8])))
+		msg.name = cString(parseAttrs(data[ifinfomsgLen:])[iflaIfname])
+		if typ == rtmNewLink {
+			if _, link, ok := parseIfinfo(data); ok {
+				msg.attrs = link.attrs
+			}
+		}
+	case rtmNewAddr, rtmDelAddr:
+		if len(data) < ifaddrmsgLen {
+			return msg, errShortLinkMsg
+		}
+		msg.index = int(nativeEndian.Uint32(data[4:8]))
+		if _, addr, ok := parseIfaddr(data); ok {
+			msg.addr = addr
+		}
+	}
+	return msg, nil
+}
+
+// maxPendingLinkMessages Watch暂存的消息上限, 只用于Collect长时间不运行时限制内存
+const maxPendingLinkMessages = 4096
+
+var errLinkMessagesLost = errors.New("rtnetlink messages lost")
+
+// linkWatcher 网卡和地址变化的消息来源; recv在超时或没有消息时返回nil, nil,
+// 丢失消息(接收缓冲区溢出)时返回errLinkMessagesLost, 由调用方重新同步
+type linkWatcher interface {
+	recv() ([]linkMessage, error)
+	close() error
+}
+
+// linkTracker 记录内核中已有的网卡, 区分网卡的创建和变化
+type linkTracker struct {
+	known map[int]string //ifindex到网卡名
+}
+
+func newLinkTracker() *linkTracker {
+	t := &linkTracker{known: make(map[int]string)}
+	if ifis, err := net.Interfaces(); err == nil {
+		for _, ifi := range ifis {
+			t.known[ifi.Index] = ifi.Name
+		}
+	}
+	return t
+}
+
+// events 根据链路消息生成EventIfiCreated/EventIfiDeleted, ifis为当前监控的网卡
+func (t *linkTracker) events(msgs []linkMessage, ifis map[string]*Ifi, now time.Time) []Event {
+	var events []Event
+	event := func(typ EventType, msg linkMessage) Event {
+		e := Event{Type: typ, Name: msg.name, Ifi: Ifi{Name: msg.name, Index: msg.index}, Time: now}
+		if ifi, exists := ifis[msg.name]; exists && ifi.Index == msg.index {
+			e.Ifi = *ifi
+		}
+		return e
+	}
+
+	for _, msg := range msgs {
+		switch msg.typ {
+		case rtmNewLink:
+			name, exists := t.known[msg.index]
+			t.known[msg.index] = msg.name
+			if !exists {
+				events = append(events, event(EventIfiCreated, msg))
+			} else if name != msg.name {
+				//改名按删除旧网卡再创建新网卡处理
+				events = append(events, event(EventIfiDeleted, linkMessage{typ: rtmDelLink, index: msg.index, name: name}))
+				events = append(events, event(EventIfiCreated, msg))
+			}
+		case rtmDelLink:
+			if _, exists := t.known[msg.index]; exists {
+				delete(t.known, msg.index)
+				events = append(events, event(EventIfiDeleted, msg))
+			}
+		}
+	}
+	return events
+}
+
+// resyncMessages 丢失消息后由完整的网卡列表生成等价的消息: 列表中的网卡和地址生成RTM_NEWLINK/RTM_NEWADDR,
+// 已知但不在列表中的网卡生成RTM_DELLINK, 快照中有而列表中没有的地址生成RTM_DELADDR
+func resyncMessages(links StaticResolver, known map[int]string, s *Snapshot) []linkMessage {
+	byIndex := make(map[int]*Link, len(links))
+	for _, link := range links {
+		byIndex[link.Index] = link
+	}
+
+	var msgs []linkMessage
+	for index, name := range known {
+		if _, exists := byIndex[index]; !exists {
+			msgs = append(msgs, linkMessage{typ: rtmDelLink, index: index, name: name})
+		}
+	}
+	for _, link := range links {
+		msgs = append(msgs, linkMessage{typ: rtmNewLink, index: link.Index, name: link.Name, attrs: link.attrs})
+		current := make([]Addr, 0, len(link.Addrs))
+		for _, addr := range link.Addrs {
+			msgs = append(msgs, linkMessage{typ: rtmNewAddr, index: link.Index, addr: addr})
+			if a, ok := newAddr(addr); ok {
+				current = append(current, a)
+			}
+		}
+		ifi, exists := s.byIndex[link.Index]
+		if !exists {
+			continue
+		}
+		for _, a := range ifi.Addrs {
+			if !containsAddr(current, a) {
+				msgs = append(msgs, linkMessage{typ: rtmDelAddr, index: link.Index, addr: a.ipNet()})
+			}
+		}
+	}
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].index < msgs[j].index })
+	return msgs
+}
+
+// applyLinkMessage 把链路或地址消息中的状态、MTU、MAC和地址更新到网卡, 返回网卡是否变化;
+// 计数、速率和速率相关的状态仍由周期采集处理
+func applyLinkMessage(ifi *Ifi, msg linkMessage) bool {
+	switch msg.typ {
+	case rtmNewLink:
+		if !msg.attrs.valid {
+			return false
+		}
+		if ifi.OperState == msg.attrs.OperState && ifi.MTU == msg.attrs.MTU && ifi.MAC == msg.attrs.MAC {
+			return false
+		}
+		//CarrierChanges留给周期采集, 否则CarrierFlaps会漏掉这段时间的抖动
+		ifi.OperState = msg.attrs.OperState
+		ifi.MTU = msg.attrs.MTU
+		ifi.MAC = msg.attrs.MAC
+		return true
+	case rtmNewAddr, rtmDelAddr:
+		if msg.addr == nil {
+			return false
+		}
+		a, ok := newAddr(msg.addr)
+		if !ok || a.Scope == ScopeLink || containsAddr(ifi.Addrs, a) == (msg.typ == rtmNewAddr) {
+			return false
+		}
+		//地址切片与已发布的快照共享, 修改时重新分配
+		addrs := make([]Addr, 0, len(ifi.Addrs)+1)
+		for _, old := range ifi.Addrs {
+			if old != a {
+				addrs = append(addrs, old)
+			}
+		}
+		if msg.typ == rtmNewAddr {
+			addrs = append(addrs, a)
+		}
+		ifi.Addrs = addrs
+		ifi.Ip = primaryIP(addrs)
+		return true
+	}
+	return false
+}
+
+// applyPending 把Watch已发布的消息应用到采集工作状态, 使本次采集的变化事件以Watch发布后的状态为基准, 不重复发布;
+// 删除的网卡由本次采集在/proc/net/dev中不再出现时移除
+func (n *NetWork) applyPending() {
+	n.pubMu.Lock()
+	msgs := n.pending
+	n.pending = nil
+	n.pubMu.Unlock()
+	if len(msgs) == 0 {
+		return
+	}
+
+	byIndex := make(map[int]*Ifi)
+	for _, ifi := range n.ifis {
+		if ifi.Netns == "" && ifi.Index > 0 {
+			byIndex[ifi.Index] = ifi
+		}
+	}
+	for _, msg := range msgs {
+		if ifi, exists := byIndex[msg.index]; exists {
+			applyLinkMessage(ifi, msg)
+		}
+	}
+}
+
+// hostLinks 本机命名空间的完整网卡列表, 用于丢失消息后重新同步
+func (n *NetWork) hostLinks() (StaticResolver, error) {
+	if l, ok := n.resolver.(linkLister); ok {
+		return l.Links()
+	}
+	return systemLinks()
+}
+
+// Watch 订阅netlink的网卡和地址变化(RTM_NEWLINK/RTM_DELLINK/RTM_NEWADDR/RTM_DELADDR),
+// 收到变化后立即更新已监控网卡的状态和地址、移除已删除的网卡并发布事件, 不做额外的全量采集, 以免打乱采集周期和速率;
+// 新出现的网卡和速率变化在下一次周期采集时处理. 丢失消息时重新dump网卡和地址并补发事件.
+// 只在发布快照时加锁, 不等待正在进行的Collect. 阻塞直到ctx结束或netlink出错
+func (n *NetWork) Watch(ctx context.Context) error {
+	w, err := openLinkWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.close()
+	return n.watch(ctx, w, newLinkTracker())
+}
+
+func (n *NetWork) watch(ctx context.Context, w linkWatcher, tracker *linkTracker) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		msgs, err := w.recv()
+		if err == errLinkMessagesLost {
+			var links StaticResolver
+			if links, err = n.hostLinks(); err == nil {
+				msgs = resyncMessages(links, tracker.known, n.Current())
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			continue
+		}
+
+		n.pubMu.Lock()
+		now := n.now()
+		cur := n.Current()
+		events := tracker.events(msgs, cur.IfiMap, now)
+		snap, changes := cur.apply(msgs, now)
+		events = append(events, changes...)
+		if snap != cur {
+			n.snapshot.Store(snap)
+		}
+		n.pending = append(n.pending, msgs...)
+		if over := len(n.pending) - maxPendingLinkMessages; over > 0 {
+			n.pending = append([]linkMessage(nil), n.pending[over:]...)
+		}
+		n.pubMu.Unlock()
+		n.events.publish(events...)
+	}
+}
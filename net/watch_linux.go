@@ -0,0 +1,72 @@
+package net
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	linkWatchTimeout = 1 //阻塞接收的超时时间(秒), 用于及时响应ctx结束
+
+	rtmgrpLink       = 0x1   //RTMGRP_LINK
+	rtmgrpIPv4Ifaddr = 0x10  //RTMGRP_IPV4_IFADDR
+	rtmgrpIPv6Ifaddr = 0x100 //RTMGRP_IPV6_IFADDR
+)
+
+type netlinkWatcher struct {
+	fd  int
+	buf []byte
+}
+
+func openLinkWatcher() (linkWatcher, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+	sa := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpLink | rtmgrpIPv4Ifaddr | rtmgrpIPv6Ifaddr,
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	tv := syscall.Timeval{Sec: linkWatchTimeout}
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return &netlinkWatcher{fd: fd, buf: make([]byte, 8*os.Getpagesize())}, nil
+}
+
+func (w *netlinkWatcher) recv() ([]linkMessage, error) {
+	n, _, err := syscall.Recvfrom(w.fd, w.buf, 0)
+	switch err {
+	case nil:
+	case syscall.EAGAIN, syscall.EINTR:
+		return nil, nil
+	case syscall.ENOBUFS:
+		//内核丢弃了消息, 由调用方重新dump网卡和地址并同步
+		return nil, errLinkMessagesLost
+	default:
+		return nil, err
+	}
+
+	msgs, err := syscall.ParseNetlinkMessage(w.buf[:n])
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]linkMessage, 0, len(msgs))
+	for _, m := range msgs {
+		msg, err := parseLinkMessage(m.Header.Type, m.Data)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, msg)
+	}
+	return ret, nil
+}
+
+func (w *netlinkWatcher) close() error {
+	return syscall.Close(w.fd)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package net
+
+func openLinkWatcher() (linkWatcher, error) {
+	return nil, errNetlinkUnsupported
+}
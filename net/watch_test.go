@@ -0,0 +1,293 @@
+package net
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func ifinfoMsg(index int, name string) []byte {
+	b := make([]byte, ifinfomsgLen)
+	nativeEndian.PutUint32(b[4:8], uint32(index))
+	return append(b, attr(iflaIfname, append([]byte(name), 0))...)
+}
+
+func TestParseLinkMessage(t *testing.T) {
+	msg, err := parseLinkMessage(rtmNewLink, ifinfoMsg(7, "veth9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.typ != rtmNewLink || msg.index != 7 || msg.name != "veth9" {
+		t.Fatalf("got %+v", msg)
+	}
+
+	link := append(ifinfoMsg(2, "eth0"), attr(iflaOperstate, []byte{2})...)
+	if msg, err := parseLinkMessage(rtmNewLink, link); err != nil || !msg.attrs.valid || msg.attrs.OperState != "down" {
+		t.Fatalf("got %+v, %v", msg, err)
+	}
+
+	addr := make([]byte, ifaddrmsgLen)
+	addr[0], addr[1] = afInet, 24
+	nativeEndian.PutUint32(addr[4:8], 3)
+	addr = append(addr, attr(ifaLocal, []byte{198, 51, 100, 1})...)
+	if msg, err := parseLinkMessage(rtmNewAddr, addr); err != nil || msg.index != 3 || msg.addr.String() != "198.51.100.1/24" {
+		t.Fatalf("got %+v, %v", msg, err)
+	}
+
+	if _, err := parseLinkMessage(rtmDelLink, []byte{0, 0}); err != errShortLinkMsg {
+		t.Fatalf("got %v, want %v", err, errShortLinkMsg)
+	}
+}
+
+func TestLinkTrackerEvents(t *testing.T) {
+	tracker := &linkTracker{known: map[int]string{2: "eth0", 3: "eth1"}}
+	ifis := map[string]*Ifi{"eth0": {Name: "eth0", Index: 2, Ip: "203.0.113.10"}}
+	now := time.Unix(1600000000, 0)
+
+	events := tracker.events([]linkMessage{
+		{typ: rtmNewLink, index: 2, name: "eth0"}, //已有网卡的变化
+		{typ: rtmNewLink, index: 7, name: "veth9"},
+		{typ: rtmNewLink, index: 3, name: "wan0"},
+		{typ: rtmDelLink, index: 2, name: "eth0"},
+		{typ: rtmDelLink, index: 9, name: "gone"},
+		{typ: rtmNewAddr, index: 7},
+	}, ifis, now)
+
+	want := []struct {
+		typ  EventType
+		name string
+	}{
+		{EventIfiCreated, "veth9"},
+		{EventIfiDeleted, "eth1"},
+		{EventIfiCreated, "wan0"},
+		{EventIfiDeleted, "eth0"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %+v", events)
+	}
+	for i, w := range want {
+		if events[i].Type != w.typ || events[i].Name != w.name {
+			t.Errorf("event %d = %v %v, want %v %v", i, events[i].Type, events[i].Name, w.typ, w.name)
+		}
+	}
+	if events[3].Ifi.Ip != "203.0.113.10" {
+		t.Errorf("deleted event should carry the monitored interface: %+v", events[3].Ifi)
+	}
+	if _, exists := tracker.known[2]; exists {
+		t.Error("deleted link still known")
+	}
+}
+
+type fakeWatcher struct {
+	batches [][]linkMessage
+	sent    []linkMessage
+	lost    bool //第一次接收时模拟接收缓冲区溢出
+	cancel  func()
+}
+
+func (w *fakeWatcher) recv() ([]linkMessage, error) {
+	if w.lost {
+		w.lost = false
+		return nil, errLinkMessagesLost
+	}
+	if len(w.batches) == 0 {
+		w.cancel()
+		return nil, nil
+	}
+	msgs := w.batches[0]
+	w.batches = w.batches[1:]
+	w.sent = append(w.sent, msgs...)
+	return msgs, nil
+}
+
+func (w *fakeWatcher) close() error {
+	return nil
+}
+
+func TestWatchUpdatesWithoutCollect(t *testing.T) {
+	n, now := newFixtureNetwork(t, "testdata/host/t0")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	before := n.Current()
+	if !before.IfiMap["eth0"].Counted {
+		t.Fatal("eth0 should be counted")
+	}
+	events, cancelSub := n.Subscribe(8)
+	defer cancelSub()
+
+	*now = now.Add(time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &fakeWatcher{
+		batches: [][]linkMessage{nil, {
+			{typ: rtmNewLink, index: 2, name: "eth0", attrs: linkAttrs{valid: true, MTU: 9000, MAC: "52:54:00:12:34:56", OperState: "down"}},
+			{typ: rtmNewAddr, index: 2, addr: &net.IPNet{IP: net.IPv4(198, 51, 100, 1).To4(), Mask: net.CIDRMask(24, 32)}},
+			{typ: rtmDelAddr, index: 2, addr: &net.IPNet{IP: net.IPv4(203, 0, 113, 10).To4(), Mask: net.CIDRMask(24, 32)}},
+			{typ: rtmNewLink, index: 7, name: "veth9"},
+		}},
+		cancel: cancel,
+	}
+	if err := n.watch(ctx, w, &linkTracker{known: map[int]string{2: "eth0", 3: "eth1"}}); err != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+
+	s := n.Current()
+	if s == before || s.Time != before.Time || n.generation != 1 {
+		t.Fatalf("watch should patch the snapshot without collecting: time=%v generation=%d", s.Time, n.generation)
+	}
+	eth0 := s.IfiMap["eth0"]
+	if eth0.OperState != "down" || eth0.MTU != 9000 || eth0.Ip != "198.51.100.1" || len(eth0.Addrs) != 1 {
+		t.Fatalf("eth0 not updated: %+v", eth0)
+	}
+	if !eth0.Counted || s.OutRecvByteAvg != before.OutRecvByteAvg {
+		t.Fatalf("patched eth0 counted = %v", eth0.Counted)
+	}
+	if before.IfiMap["eth0"].OperState != "up" || before.IfiMap["eth0"].Ip != "203.0.113.10" {
+		t.Fatal("published snapshot was modified")
+	}
+	if ifi, err := s.IfiByIP("198.51.100.1"); err != nil || ifi.Name != "eth0" {
+		t.Fatalf("IfiByIP = %v, %v", ifi, err)
+	}
+	if _, err := s.IfiByIP("203.0.113.10"); err == nil {
+		t.Fatal("removed address still resolves")
+	}
+
+	want := []EventType{EventIfiCreated, EventLinkDown, EventAddrAdded, EventAddrRemoved}
+	for _, typ := range want {
+		select {
+		case e := <-events:
+			if e.Type != typ {
+				t.Fatalf("got %v, want %v", e.Type, typ)
+			}
+		default:
+			t.Fatalf("missing %v event", typ)
+		}
+	}
+
+	//重复的消息不产生事件
+	if patched, events := s.apply(w.sent, *now); patched != s || len(events) != 0 {
+		t.Fatalf("duplicate events %+v", events)
+	}
+
+	//下一次采集以Watch发布后的状态为基准, 不重复发布地址变化
+	n.resolver.(StaticResolver)["eth0"] = &Link{Index: 2, Name: "eth0", Addrs: []net.Addr{mustCIDR(t, "198.51.100.1/24")}}
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.pending) != 0 {
+		t.Fatalf("pending messages not applied: %+v", n.pending)
+	}
+	for {
+		select {
+		case e := <-events:
+			if e.Type == EventAddrAdded && e.Addr.IP == "198.51.100.1" || e.Type == EventAddrRemoved && e.Addr.IP == "203.0.113.10" {
+				t.Fatalf("address event published twice: %+v", e)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func TestWatchDelLink(t *testing.T) {
+	n, _ := newFixtureNetwork(t, "testdata/host/t0")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	events, cancelSub := n.Subscribe(8)
+	defer cancelSub()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &fakeWatcher{batches: [][]linkMessage{{{typ: rtmDelLink, index: 3, name: "eth1"}}}, cancel: cancel}
+	if err := n.watch(ctx, w, &linkTracker{known: map[int]string{2: "eth0", 3: "eth1"}}); err != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+
+	s := n.Current()
+	if _, exists := s.IfiMap["eth1"]; exists || len(s.IfiNames) != 1 || s.IfiNames[0] != "eth0" {
+		t.Fatalf("eth1 not removed: %v", s.IfiNames)
+	}
+	if _, err := s.IfiByIfIndex(3); err == nil {
+		t.Fatal("removed interface still resolves by index")
+	}
+	if _, err := s.IfiByIP("10.0.0.5"); err == nil {
+		t.Fatal("removed interface still resolves by IP")
+	}
+	if e := <-events; e.Type != EventIfiDeleted || e.Ifi.Ip != "10.0.0.5" {
+		t.Fatalf("got %+v", e)
+	}
+}
+
+func TestWatchResync(t *testing.T) {
+	n, _ := newFixtureNetwork(t, "testdata/host/t0")
+	if err := n.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	events, cancelSub := n.Subscribe(8)
+	defer cancelSub()
+
+	//溢出期间eth0的地址被删除, docker0被创建, gone被删除
+	n.resolver.(StaticResolver)["eth0"] = &Link{Index: 2, Name: "eth0"}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &fakeWatcher{lost: true, cancel: cancel}
+	if err := n.watch(ctx, w, &linkTracker{known: map[int]string{1: "lo", 2: "eth0", 3: "eth1", 9: "gone"}}); err != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+
+	if eth0 := n.Current().IfiMap["eth0"]; eth0.Ip != "" || len(eth0.Addrs) != 0 {
+		t.Fatalf("eth0 addresses not resynced: %+v", eth0.Addrs)
+	}
+	want := []struct {
+		typ  EventType
+		name string
+	}{
+		{EventIfiCreated, "docker0"},
+		{EventIfiDeleted, "gone"},
+		{EventAddrRemoved, "eth0"},
+	}
+	for _, w := range want {
+		select {
+		case e := <-events:
+			if e.Type != w.typ || e.Name != w.name {
+				t.Fatalf("got %v %v, want %v %v", e.Type, e.Name, w.typ, w.name)
+			}
+		default:
+			t.Fatalf("missing %v %v", w.typ, w.name)
+		}
+	}
+}
+
+func TestChangeEvents(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	a := Addr{IP: "203.0.113.10", PrefixLen: 24, Family: FamilyIPv4, Scope: ScopeGlobal}
+	b := Addr{IP: "203.0.113.11", PrefixLen: 24, Family: FamilyIPv4, Scope: ScopeGlobal}
+
+	prev := &Ifi{Name: "eth0", OperState: "up", Speed: 1000, Addrs: []Addr{a}}
+	cur := &Ifi{Name: "eth0", OperState: "down", Speed: 0, Addrs: []Addr{b}}
+	events := changeEvents("eth0", prev, cur, now)
+	if len(events) != 4 {
+		t.Fatalf("got %+v", events)
+	}
+	if events[0].Type != EventLinkDown || events[0].PrevOperState != "up" {
+		t.Errorf("got %+v", events[0])
+	}
+	if events[1].Type != EventSpeedChanged || events[1].PrevSpeed != 1000 || events[1].Ifi.Speed != 0 {
+		t.Errorf("got %+v", events[1])
+	}
+	if events[2].Type != EventAddrAdded || events[2].Addr != b {
+		t.Errorf("got %+v", events[2])
+	}
+	if events[3].Type != EventAddrRemoved || events[3].Addr != a {
+		t.Errorf("got %+v", events[3])
+	}
+
+	events = changeEvents("eth0", cur, &Ifi{Name: "eth0", OperState: "up", Addrs: []Addr{b}}, now)
+	if len(events) != 1 || events[0].Type != EventLinkUp || events[0].PrevOperState != "down" {
+		t.Fatalf("got %+v", events)
+	}
+
+	if events := changeEvents("eth0", prev, prev, now); len(events) != 0 {
+		t.Fatalf("got %+v", events)
+	}
+}
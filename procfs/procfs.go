@@ -0,0 +1,83 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultRoot 本机根目录
+const DefaultRoot = "/"
+
+// FS 以root为根目录的/proc和/sys文件树, 可以指向现场采集回来的目录用于测试和问题复现
+type FS struct {
+	root string
+}
+
+func NewFS(root string) FS {
+	if root == "" {
+		root = DefaultRoot
+	}
+	return FS{root: filepath.Clean(root)}
+}
+
+// Root 根目录
+func (fs FS) Root() string {
+	if fs.root == "" {
+		return DefaultRoot
+	}
+	return fs.root
+}
+
+// IsHost 是否为本机根目录
+func (fs FS) IsHost() bool {
+	return fs.Root() == DefaultRoot
+}
+
+// Path 根目录下的路径
+func (fs FS) Path(elem ...string) string {
+	return filepath.Join(append([]string{fs.Root()}, elem...)...)
+}
+
+// Proc /proc下的路径
+func (fs FS) Proc(elem ...string) string {
+	return fs.Path(append([]string{"proc"}, elem...)...)
+}
+
+// Sys /sys下的路径
+func (fs FS) Sys(elem ...string) string {
+	return fs.Path(append([]string{"sys"}, elem...)...)
+}
+
+// Open 打开文件
+func (fs FS) Open(path string) (*os.File, error) {
+	return os.Open(path)
+}
+
+// ReadString 读取文件内容并去掉首尾空白
+func (fs FS) ReadString(path string) (string, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bytes)), nil
+}
+
+// ReadUint 读取只包含一个无符号整数的文件
+func (fs FS) ReadUint(path string) (uint64, error) {
+	str, err := fs.ReadString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(str, 10, 64)
+}
+
+// ReadInt 读取只包含一个整数的文件
+func (fs FS) ReadInt(path string) (int64, error) {
+	str, err := fs.ReadString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(str, 10, 64)
+}
@@ -0,0 +1,58 @@
+package tcp
+
+import (
+	"github.com/enoch300/collectd/collector"
+)
+
+var _ collector.Collector = (*TCP)(nil)
+
+const collectorName = "tcp"
+
+var metrics = []struct {
+	desc  collector.Desc
+	value func(t *TCP) float64
+}{
+	{
+		desc:  collector.Desc{Name: "tcp_out_segs", Help: "TCP发包数", Unit: "seg"},
+		value: func(t *TCP) float64 { return t.OutSegs },
+	},
+	{
+		desc:  collector.Desc{Name: "tcp_retrans_segs", Help: "TCP重传数", Unit: "seg"},
+		value: func(t *TCP) float64 { return t.RetransSegs },
+	},
+	{
+		desc:  collector.Desc{Name: "tcp_out_segs_avg", Help: "平均每秒TCP发包数", Unit: "seg/s"},
+		value: func(t *TCP) float64 { return t.OutSegsAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "tcp_retrans_segs_avg", Help: "平均每秒TCP重传数", Unit: "seg/s"},
+		value: func(t *TCP) float64 { return t.RetransSegsAvg },
+	},
+	{
+		desc:  collector.Desc{Name: "tcp_retran_rate", Help: "TCP重传率", Unit: "%"},
+		value: func(t *TCP) float64 { return t.RetranRate },
+	},
+}
+
+// Name 采集器名称
+func (t *TCP) Name() string {
+	return collectorName
+}
+
+// Describe TCP采集器指标描述
+func (t *TCP) Describe() []collector.Desc {
+	descs := make([]collector.Desc, 0, len(metrics))
+	for _, m := range metrics {
+		descs = append(descs, m.desc)
+	}
+	return descs
+}
+
+// Snapshot 最近一次采集的指标快照
+func (t *TCP) Snapshot() collector.Snapshot {
+	samples := make(collector.SampleList, 0, len(metrics))
+	for _, m := range metrics {
+		samples = append(samples, collector.Sample{Name: m.desc.Name, Value: m.value(t)})
+	}
+	return samples
+}
@@ -2,57 +2,131 @@ package tcp
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"github.com/enoch300/collectd/procfs"
 	"github.com/enoch300/collectd/utils"
 	"io"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var ErrNoTcpStat = errors.New("tcp stat not found")
+
 type TCP struct {
-	OutSegs     float64 //TCP发包数
-	RetransSegs float64 //TCP重传数
-	RetranRate  float64 //TCP重传率
-	LastTime    int64   //上次采集时间
+	OutSegs        float64   //TCP发包数
+	RetransSegs    float64   //TCP重传数
+	OutSegsAvg     float64   //一个周期平均每秒TCP发包数
+	RetransSegsAvg float64   //一个周期平均每秒TCP重传数
+	RetranRate     float64   //TCP重传率
+	Resets         int       //计数器重置次数
+	LastTime       time.Time //上次采集时间(含单调时钟)
+
+	fs  procfs.FS
+	now func() time.Time
 }
 
-// Detect 采集整机重传率
-func (t *TCP) Collect() error {
-	f, err := os.Open("/proc/net/snmp")
-	if err != nil {
-		return err
+type Option func(t *TCP)
+
+// WithRoot 指定/proc所在的根目录
+func WithRoot(root string) Option {
+	return func(t *TCP) {
+		t.fs = procfs.NewFS(root)
 	}
-	defer f.Close()
-	reader := bufio.NewReader(f)
+}
+
+// parseSnmp 解析 /proc/net/snmp 中Tcp的字段, 返回字段名到值的映射
+func parseSnmp(r io.Reader) (map[string]float64, error) {
+	var header []string
+	reader := bufio.NewReader(r)
 	for {
 		line, err := reader.ReadString('\n')
-		if err == io.EOF {
-			break
+		if err != nil && err != io.EOF {
+			return nil, err
 		}
 
-		if err != nil {
-			return err
+		//Tcp: RtoAlgorithm RtoMin ... 为字段名, 下一行 Tcp: 1 200 ... 为值
+		if strings.HasPrefix(line, "Tcp:") {
+			fields := strings.Fields(line)[1:]
+			if header == nil {
+				header = fields
+			} else {
+				values := make(map[string]float64, len(header))
+				for i, name := range header {
+					if i >= len(fields) {
+						break
+					}
+					values[name], _ = strconv.ParseFloat(fields[i], 64)
+				}
+				return values, nil
+			}
 		}
 
-		if !strings.Contains(line, "Tcp") || strings.Contains(line, "RtoAlgorithm") {
-			continue
+		if err == io.EOF {
+			break
 		}
+	}
+	return nil, ErrNoTcpStat
+}
+
+// counterDelta 计数器两次采集之间的增量; /proc/net/snmp中的计数是unsigned long,
+// 计数变小说明被重置(如网络命名空间重建), 此时增量不可用
+func counterDelta(prev, cur float64) (float64, bool) {
+	if cur < prev {
+		return 0, false
+	}
+	return cur - prev, true
+}
 
-		fields := strings.Fields(line)
-		outSegs, _ := strconv.ParseFloat(fields[11], 64)
-		retransSegs, _ := strconv.ParseFloat(fields[12], 64)
+// Collect 采集整机重传率
+func (t *TCP) Collect(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-		if t.LastTime == 0 { //第一次采集，没有时间差，只赋值不计算
+	f, err := t.fs.Open(t.fs.Proc("net", "snmp"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-		} else {
-			t.RetranRate = utils.FormatFloat((retransSegs - t.RetransSegs) / (outSegs - t.OutSegs) * 100)
+	values, err := parseSnmp(f)
+	if err != nil {
+		return err
+	}
+
+	now := t.now()
+	outSegs := values["OutSegs"]
+	retransSegs := values["RetransSegs"]
+
+	if t.LastTime.IsZero() { //第一次采集，没有时间差，只赋值不计算
+
+	} else {
+		diffTime := now.Sub(t.LastTime).Seconds()
+		if diffTime <= 0 {
+			//与上次采集没有时间差(重复采集)，保留上次结果和基准
+			return nil
 		}
 
-		t.OutSegs = outSegs
-		t.RetransSegs = retransSegs
-		t.LastTime = time.Now().Unix()
+		outDelta, outOk := counterDelta(t.OutSegs, outSegs)
+		retransDelta, retransOk := counterDelta(t.RetransSegs, retransSegs)
+		if outOk && retransOk {
+			t.OutSegsAvg = outDelta / diffTime
+			t.RetransSegsAvg = retransDelta / diffTime
+			if outDelta > 0 {
+				t.RetranRate = utils.FormatFloat(retransDelta / outDelta * 100)
+			}
+		} else {
+			//计数器被重置, 丢弃这个周期并以当前值为新的基准
+			t.OutSegsAvg, t.RetransSegsAvg, t.RetranRate = 0, 0, 0
+			t.Resets++
+		}
 	}
+
+	t.OutSegs = outSegs
+	t.RetransSegs = retransSegs
+	t.LastTime = now
 	return nil
 }
 
@@ -60,11 +134,16 @@ func (t *TCP) GetRetranRate() float64 {
 	return t.RetranRate
 }
 
-func NewTcp() *TCP {
-	return &TCP{
+func NewTcp(opts ...Option) *TCP {
+	t := &TCP{
 		OutSegs:     0,
 		RetransSegs: 0,
 		RetranRate:  0,
-		LastTime:    0,
+		fs:          procfs.NewFS(procfs.DefaultRoot),
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(t)
 	}
+	return t
 }
@@ -0,0 +1,69 @@
+package tcp
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enoch300/collectd/procfs"
+)
+
+func TestCounterDelta(t *testing.T) {
+	if d, ok := counterDelta(100, 250); !ok || d != 150 {
+		t.Fatalf("got %v, %v", d, ok)
+	}
+	//计数器变小, 不能算出负的增量
+	if _, ok := counterDelta(250, 100); ok {
+		t.Fatal("counter going backwards should be a reset")
+	}
+}
+
+func TestParseSnmp(t *testing.T) {
+	f, err := os.Open("testdata/t0/proc/net/snmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	values, err := parseSnmp(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["OutSegs"] != 1000 || values["RetransSegs"] != 0 || values["InSegs"] != 1539 {
+		t.Fatalf("unexpected values: %v", values)
+	}
+}
+
+func TestParseSnmpMissing(t *testing.T) {
+	snmp := "Ip: Forwarding DefaultTTL\nIp: 2 64\n"
+	if _, err := parseSnmp(strings.NewReader(snmp)); err != ErrNoTcpStat {
+		t.Fatalf("got %v, want %v", err, ErrNoTcpStat)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tcp := NewTcp(WithRoot("testdata/t0"))
+	tcp.now = func() time.Time { return now }
+
+	if err := tcp.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tcp.GetRetranRate() != 0 {
+		t.Fatalf("first collect rate = %v, want 0", tcp.GetRetranRate())
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	tcp.fs = procfs.NewFS("testdata/t1")
+	if err := tcp.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tcp.GetRetranRate() != 2.5 {
+		t.Fatalf("rate = %v, want 2.5", tcp.GetRetranRate())
+	}
+	if tcp.OutSegsAvg != 4000 || tcp.RetransSegsAvg != 100 {
+		t.Fatalf("got out=%v retrans=%v per second, want 4000 and 100", tcp.OutSegsAvg, tcp.RetransSegsAvg)
+	}
+}
@@ -8,24 +8,61 @@
 package main
 
 import (
-	"collectd/net"
+	"context"
 	"fmt"
 	"time"
+
+	"github.com/enoch300/collectd/aggregate"
+	"github.com/enoch300/collectd/collector"
+	"github.com/enoch300/collectd/net"
+	"github.com/enoch300/collectd/tcp"
 )
 
 func main() {
-	Net := net.NewNetwork([]string{}, []string{"docker", "lo"}, []string{}, []string{})
+	registry := collector.NewRegistry()
+	network := net.NewNetwork([]string{}, []string{"lo"}, []string{}, []string{},
+		net.WithContainers(net.NewDockerResolver("")))
+	registry.Register(network)
+	go func() {
+		if err := network.Watch(context.Background()); err != nil {
+			fmt.Printf("network.Watch: %v\n", err)
+		}
+	}()
+	events, _ := network.Subscribe(64)
+	go func() {
+		for e := range events {
+			fmt.Printf("net event >>> %v %v\n", e.Type, e.Name)
+		}
+	}()
+	window, err := aggregate.NewCollector(network,
+		aggregate.WithMetrics("net_recv_byte_avg", "net_send_byte_avg"),
+		aggregate.WithStateFile("net_window.json"))
+	if err != nil {
+		fmt.Printf("aggregate.NewCollector: %v\n", err)
+	} else {
+		registry.Register(window)
+	}
+	billing, err := aggregate.NewCollector(network,
+		aggregate.WithName("net_billing"),
+		aggregate.WithWindows(aggregate.BillingWindow),
+		aggregate.WithMetrics("net_recv_byte_avg", "net_send_byte_avg", "net_iface_recv_byte_avg", "net_iface_send_byte_avg"),
+		aggregate.WithStateFile("net_billing.json"))
+	if err != nil {
+		fmt.Printf("aggregate.NewCollector: %v\n", err)
+	} else {
+		registry.Register(billing)
+	}
+	registry.Register(tcp.NewTcp())
+
 	for {
-		err := Net.Collect()
-		if err != nil {
-			fmt.Printf("Net.Collect: %v\n", err.Error())
-			time.Sleep(60 * time.Second)
-			continue
+		for name, err := range registry.CollectAll(context.Background()) {
+			fmt.Printf("%v.Collect: %v\n", name, err.Error())
 		}
 
-		fmt.Printf("Monitor interface: %v\n", Net.IfiNames)
-		for iFace, _ := range Net.IfiMap {
-			fmt.Printf("Iface details >>> iface: %v\n", iFace)
+		for name, samples := range registry.Gather() {
+			for _, sample := range samples {
+				fmt.Printf("%v >>> %v %v: %v\n", name, sample.Name, sample.Labels, sample.Value)
+			}
 		}
 		time.Sleep(5 * time.Second)
 	}